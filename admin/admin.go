@@ -0,0 +1,215 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/Deutsche-Boerse/edt-sftp/conf"
+	"github.com/Deutsche-Boerse/edt-sftp/host2host"
+	"github.com/Deutsche-Boerse/edt-sftp/ledger"
+	"github.com/Deutsche-Boerse/edt-sftp/runner"
+
+	"github.com/rs/zerolog/log"
+)
+
+//Prefix is URL path the handler is mounted at
+const Prefix = "/admin/"
+
+// operations of admin API
+const (
+	OpRequeue    = "requeue"
+	OpReprocess  = "reprocess"
+	OpResend     = "resend"
+	OpDuplicates = "duplicates"
+)
+
+//defaultSince is look-back period of duplicates report when since is not given
+const defaultSince = 24 * time.Hour
+
+//File is outcome of operation for single file
+type File struct {
+	File  string `json:"file"`
+	Sent  bool   `json:"sent"`
+	Error string `json:"error,omitempty"`
+}
+
+//Duplicate is delivery which was not sent because its content has been already sent as DuplicateOf
+type Duplicate struct {
+	File        string    `json:"file"`
+	Partner     string    `json:"partner,omitempty"`
+	Hash        string    `json:"hash"`
+	DuplicateOf string    `json:"duplicateOf"`
+	Time        time.Time `json:"time"`
+}
+
+//Result is reply of admin API
+type Result struct {
+	RunID      string      `json:"runId,omitempty"`
+	Files      []File      `json:"files,omitempty"`
+	Duplicates []Duplicate `json:"duplicates,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+//Operations are manual operations of operators
+type Operations interface {
+	//Requeue renames remote .edt file back and recreates its markers
+	Requeue(ctx context.Context, remoteFile string) (Result, error)
+	//Reprocess processes remote file regardless of its markers
+	Reprocess(ctx context.Context, remoteFile string) (Result, error)
+	//Resend sends archived delivery of partner to api-gateway again
+	Resend(ctx context.Context, partner string, name string) (Result, error)
+	//Duplicates reports deliveries recognized as duplicates after since
+	Duplicates(ctx context.Context, since time.Time) (Result, error)
+}
+
+//Handler serves `POST /admin/requeue?path=<remote file>`, `POST /admin/reprocess?path=<remote file>`,
+//`POST /admin/resend?partner=<partner>&name=<file>` and `GET /admin/duplicates?since=<duration>`.
+//Requests must carry `Authorization: Bearer <token>`. 409 is returned when operation cannot run because
+//download is in progress
+func Handler(token string, ops Operations) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			writeResult(w, http.StatusUnauthorized, Result{Error: "unauthorized"})
+			return
+		}
+		op := strings.TrimPrefix(r.URL.Path, Prefix)
+		method := http.MethodPost
+		if op == OpDuplicates {
+			method = http.MethodGet
+		}
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeResult(w, http.StatusMethodNotAllowed, Result{Error: "method not allowed"})
+			return
+		}
+		query := r.URL.Query()
+		var result Result
+		var err error
+		switch op {
+		case OpRequeue, OpReprocess:
+			remoteFile := query.Get("path")
+			if remoteFile == "" {
+				writeResult(w, http.StatusBadRequest, Result{Error: "expected path"})
+				return
+			}
+			if op == OpRequeue {
+				result, err = ops.Requeue(r.Context(), remoteFile)
+			} else {
+				result, err = ops.Reprocess(r.Context(), remoteFile)
+			}
+		case OpResend:
+			if query.Get("name") == "" {
+				writeResult(w, http.StatusBadRequest, Result{Error: "expected partner and name"})
+				return
+			}
+			result, err = ops.Resend(r.Context(), query.Get("partner"), query.Get("name"))
+		case OpDuplicates:
+			since := defaultSince
+			if query.Get("since") != "" {
+				if since, err = time.ParseDuration(query.Get("since")); err != nil {
+					writeResult(w, http.StatusBadRequest, Result{Error: "invalid since"})
+					return
+				}
+			}
+			result, err = ops.Duplicates(r.Context(), time.Now().Add(-since))
+		default:
+			writeResult(w, http.StatusNotFound, Result{Error: "unknown operation"})
+			return
+		}
+		status := http.StatusOK
+		if err != nil {
+			log.Error().Err(err).Msgf("admin %s failed", r.URL.String())
+			result.Error = err.Error()
+			status = http.StatusInternalServerError
+			if err == runner.ErrSkipped || err == runner.ErrStopped {
+				status = http.StatusConflict
+			}
+		}
+		writeResult(w, status, result)
+	})
+}
+
+func authorized(r *http.Request, token string) bool {
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+func writeResult(w http.ResponseWriter, status int, result Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+//runnerOperations executes operations as runs of runner, so they never overlap with scheduled downloads
+type runnerOperations struct {
+	config *conf.SftpConfig
+	jobs   *runner.Runner
+}
+
+//New creates operations executed by jobs
+func New(config *conf.SftpConfig, jobs *runner.Runner) Operations {
+	return &runnerOperations{config: config, jobs: jobs}
+}
+
+func (o *runnerOperations) Requeue(ctx context.Context, remoteFile string) (Result, error) {
+	result := Result{Files: []File{{File: remoteFile}}}
+	var err error
+	result.RunID, err = o.jobs.Do(ctx, func(ctx context.Context) error {
+		return host2host.Requeue(ctx, o.config, remoteFile)
+	})
+	return result, err
+}
+
+func (o *runnerOperations) Reprocess(ctx context.Context, remoteFile string) (Result, error) {
+	var result Result
+	var err error
+	result.RunID, err = o.jobs.Do(ctx, func(ctx context.Context) error {
+		downloads, err := host2host.Reprocess(ctx, o.config, remoteFile)
+		for _, download := range downloads {
+			result.Files = append(result.Files, file(download.SourcePathOriginal, download))
+		}
+		return err
+	})
+	return result, err
+}
+
+func (o *runnerOperations) Resend(ctx context.Context, partner string, name string) (Result, error) {
+	var result Result
+	var err error
+	result.RunID, err = o.jobs.Do(ctx, func(ctx context.Context) error {
+		download, err := host2host.Resend(ctx, o.config, partner, name)
+		if download != nil {
+			result.Files = append(result.Files, file(name, download))
+		}
+		return err
+	})
+	return result, err
+}
+
+func (o *runnerOperations) Duplicates(ctx context.Context, since time.Time) (Result, error) {
+	var result Result
+	var err error
+	//ledger is locked by running download, report waits for its turn as well
+	result.RunID, err = o.jobs.Do(ctx, func(ctx context.Context) error {
+		entries, err := host2host.Duplicates(o.config, since)
+		for _, entry := range entries {
+			result.Duplicates = append(result.Duplicates, Duplicate{File: entry.Key.Path, Partner: entry.Partner,
+				Hash: entry.Key.Hash, DuplicateOf: entry.DuplicateOf.Path, Time: entry.Stages[ledger.StageDuplicate].Time})
+		}
+		return err
+	})
+	return result, err
+}
+
+func file(name string, download *structs.DownloadInfo) File {
+	f := File{File: name, Sent: download.Sent}
+	if download.Error != nil {
+		f.Error = download.Error.Error()
+	}
+	return f
+}
@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/runner"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOperations struct {
+	calls []string
+	err   error
+}
+
+func (f *fakeOperations) Requeue(ctx context.Context, remoteFile string) (Result, error) {
+	f.calls = append(f.calls, OpRequeue+" "+remoteFile)
+	return Result{RunID: "1", Files: []File{{File: remoteFile}}}, f.err
+}
+
+func (f *fakeOperations) Reprocess(ctx context.Context, remoteFile string) (Result, error) {
+	f.calls = append(f.calls, OpReprocess+" "+remoteFile)
+	return Result{RunID: "2", Files: []File{{File: remoteFile, Sent: true}}}, f.err
+}
+
+func (f *fakeOperations) Resend(ctx context.Context, partner string, name string) (Result, error) {
+	f.calls = append(f.calls, OpResend+" "+partner+"/"+name)
+	return Result{RunID: "3"}, f.err
+}
+
+func (f *fakeOperations) Duplicates(ctx context.Context, since time.Time) (Result, error) {
+	f.calls = append(f.calls, OpDuplicates+" "+time.Since(since).Round(time.Hour).String())
+	return Result{RunID: "4", Duplicates: []Duplicate{{File: "/COBA/KV0012.zip", DuplicateOf: "/COBA/KV0011.zip"}}}, f.err
+}
+
+func request(handler http.Handler, method string, url string, token string) (*httptest.ResponseRecorder, Result) {
+	r := httptest.NewRequest(method, url, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var result Result
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return w, result
+}
+
+func TestHandler(t *testing.T) {
+	//arrange
+	ops := &fakeOperations{}
+	handler := Handler("secret", ops)
+
+	//act
+	requeued, requeueResult := request(handler, http.MethodPost, Prefix+"requeue?path=/COBA/KV0011.zip.edt", "secret")
+	reprocessed, reprocessResult := request(handler, http.MethodPost, Prefix+"reprocess?path=/COBA/KV0011.zip", "secret")
+	resent, _ := request(handler, http.MethodPost, Prefix+"resend?partner=COBA&name=KV0011.zip", "secret")
+
+	//assert
+	assert.Equal(t, http.StatusOK, requeued.Code)
+	assert.Equal(t, http.StatusOK, reprocessed.Code)
+	assert.Equal(t, http.StatusOK, resent.Code)
+	assert.Equal(t, "1", requeueResult.RunID)
+	assert.Equal(t, []File{{File: "/COBA/KV0011.zip", Sent: true}}, reprocessResult.Files)
+	assert.Equal(t, []string{"requeue /COBA/KV0011.zip.edt", "reprocess /COBA/KV0011.zip", "resend COBA/KV0011.zip"}, ops.calls)
+}
+
+func TestHandlerDuplicates(t *testing.T) {
+	//arrange
+	ops := &fakeOperations{}
+	handler := Handler("secret", ops)
+
+	//act
+	reported, result := request(handler, http.MethodGet, Prefix+"duplicates?since=48h", "secret")
+	defaulted, _ := request(handler, http.MethodGet, Prefix+"duplicates", "secret")
+	post, _ := request(handler, http.MethodPost, Prefix+"duplicates", "secret")
+	invalid, _ := request(handler, http.MethodGet, Prefix+"duplicates?since=yesterday", "secret")
+
+	//assert
+	assert.Equal(t, http.StatusOK, reported.Code)
+	assert.Equal(t, http.StatusOK, defaulted.Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, post.Code)
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+	assert.Equal(t, []Duplicate{{File: "/COBA/KV0012.zip", DuplicateOf: "/COBA/KV0011.zip"}}, result.Duplicates)
+	assert.Equal(t, []string{"duplicates 48h0m0s", "duplicates 24h0m0s"}, ops.calls)
+}
+
+func TestHandlerRejectsRequests(t *testing.T) {
+	//arrange
+	ops := &fakeOperations{}
+	handler := Handler("secret", ops)
+	disabled := Handler("", ops)
+
+	//act
+	noToken, _ := request(handler, http.MethodPost, Prefix+"requeue?path=/COBA/KV0011.zip", "")
+	wrongToken, _ := request(handler, http.MethodPost, Prefix+"requeue?path=/COBA/KV0011.zip", "guess")
+	emptyToken, _ := request(disabled, http.MethodPost, Prefix+"requeue?path=/COBA/KV0011.zip", "")
+	get, _ := request(handler, http.MethodGet, Prefix+"requeue?path=/COBA/KV0011.zip", "secret")
+	noPath, _ := request(handler, http.MethodPost, Prefix+"requeue", "secret")
+	unknown, _ := request(handler, http.MethodPost, Prefix+"delete?path=/COBA/KV0011.zip", "secret")
+
+	//assert
+	assert.Equal(t, http.StatusUnauthorized, noToken.Code)
+	assert.Equal(t, http.StatusUnauthorized, wrongToken.Code)
+	assert.Equal(t, http.StatusUnauthorized, emptyToken.Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, get.Code)
+	assert.Equal(t, http.StatusBadRequest, noPath.Code)
+	assert.Equal(t, http.StatusNotFound, unknown.Code)
+	assert.Empty(t, ops.calls)
+}
+
+func TestHandlerErrors(t *testing.T) {
+	//arrange
+	busy := Handler("secret", &fakeOperations{err: runner.ErrSkipped})
+	failing := Handler("secret", &fakeOperations{err: errors.New("cannot rename")})
+
+	//act
+	conflict, conflictResult := request(busy, http.MethodPost, Prefix+"reprocess?path=/COBA/KV0011.zip", "secret")
+	failed, failedResult := request(failing, http.MethodPost, Prefix+"requeue?path=/COBA/KV0011.zip", "secret")
+
+	//assert
+	assert.Equal(t, http.StatusConflict, conflict.Code)
+	assert.Equal(t, runner.ErrSkipped.Error(), conflictResult.Error)
+	assert.Equal(t, http.StatusInternalServerError, failed.Code)
+	assert.Equal(t, "cannot rename", failedResult.Error)
+}
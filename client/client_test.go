@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"github.com/pkg/errors"
 	"io"
 	"os"
@@ -32,10 +33,12 @@ var (
 var testData = struct {
 	InPath   string
 	OutPath  string
+	WorkPath string
 	SftpCoba string
 }{
 	filepath.Join("testdata", "in"),
 	filepath.Join("testdata", "out"),
+	filepath.Join("testdata", "out", testedZip+"-work"),
 	filepath.Join("/home/ec2-user/COBA/"),
 }
 
@@ -46,7 +49,7 @@ func TestSendMultipleFilesRequest(t *testing.T) {
 	//arrange
 	config, err := conf.NewFactory().Get()
 	assert.NoError(t, err)
-	sftpClient, err := NewFactory(ClientOptions{config}).Get()
+	sftpClient, err := NewFactory(ClientOptions{SftpConfig: config}).Get()
 	assert.NoError(t, err)
 	testee1 := filepath.Join(testData.InPath, data1)
 	testee2 := filepath.Join(testData.InPath, data2)
@@ -54,7 +57,7 @@ func TestSendMultipleFilesRequest(t *testing.T) {
 	downloads := []*structs.DownloadInfo{{Unzipped: []string{testee1, testee2, termsAndConditions}}}
 
 	//act
-	err = sftpClient.SendToEdt(downloads)
+	err = sftpClient.SendToEdt(context.Background(), downloads)
 	//assert
 	assert.NoError(t, err)
 }
@@ -66,14 +69,14 @@ func TestSendOneFilesRequest(t *testing.T) {
 	//arrange
 	config, err := conf.NewFactory().Get()
 	assert.NoError(t, err)
-	sftpClient, err := NewFactory(ClientOptions{config}).Get()
+	sftpClient, err := NewFactory(ClientOptions{SftpConfig: config}).Get()
 	assert.NoError(t, err)
 	testee1 := filepath.Join(testData.InPath, data1)
 	termsAndConditions := filepath.Join(testData.InPath, tc)
 	downloads := []*structs.DownloadInfo{{Unzipped: []string{testee1, termsAndConditions}}}
 
 	//act
-	err = sftpClient.SendToEdt(downloads)
+	err = sftpClient.SendToEdt(context.Background(), downloads)
 	//assert
 	assert.NoError(t, err)
 }
@@ -86,13 +89,13 @@ func TestSendZeroFilesRequest(t *testing.T) {
 	//arrange
 	config, err := conf.NewFactory().Get()
 	assert.NoError(t, err)
-	sftpClient, err := NewFactory(ClientOptions{config}).Get()
+	sftpClient, err := NewFactory(ClientOptions{SftpConfig: config}).Get()
 	assert.NoError(t, err)
 
 	downloads := []*structs.DownloadInfo{{Unzipped: []string{}}}
 
 	//act
-	err = sftpClient.SendToEdt(downloads)
+	err = sftpClient.SendToEdt(context.Background(), downloads)
 
 	//assert
 	assert.NoError(t, err)
@@ -108,22 +111,23 @@ func TestCleaningWhenEverythingGoesFine(t *testing.T) {
 	//arrange
 	err = copyToRemote(testData.SftpCoba, testedEdt, testedResponse)
 	assert.NoError(t, err)
-	err = copyToLocal(testData.OutPath, testedZip, data1, data2, tc)
+	err = copyToLocal(testData.WorkPath, testedZip, data1, data2, tc)
 	assert.NoError(t, err)
 	config, err = conf.NewFactory().Get()
 	assert.NoError(t, err)
-	sftpClient, err := NewFactory(ClientOptions{config}).Get()
+	sftpClient, err := NewFactory(ClientOptions{SftpConfig: config}).Get()
 	assert.NoError(t, err)
 
 	downloads := []*structs.DownloadInfo{
 		{
 			Error:           nil,
-			DestinationPath: path.Join(path.Join(testData.OutPath, testedZip)),
+			WorkDir:         testData.WorkPath,
+			DestinationPath: path.Join(path.Join(testData.WorkPath, testedZip)),
 			SourcePath:      path.Join(testData.SftpCoba, testedEdt),
 			Unzipped: []string{
-				path.Join(testData.OutPath, data1),
-				path.Join(testData.OutPath, data2),
-				path.Join(testData.OutPath, tc),
+				path.Join(testData.WorkPath, data1),
+				path.Join(testData.WorkPath, data2),
+				path.Join(testData.WorkPath, tc),
 			},
 			ResponsePath:       path.Join(testData.SftpCoba, testedResponse),
 			SourcePathOriginal: path.Join(testData.SftpCoba, testedZip),
@@ -131,7 +135,7 @@ func TestCleaningWhenEverythingGoesFine(t *testing.T) {
 	}
 
 	//act
-	err = sftpClient.Clean(downloads)
+	err = sftpClient.Clean(context.Background(), downloads)
 
 	//assert
 	assert.NoError(t, err)
@@ -140,15 +144,18 @@ func TestCleaningWhenEverythingGoesFine(t *testing.T) {
 	assert.True(t, exists)
 	utils.CreateClient().Exists(filepath.Join(testData.SftpCoba, testedEdt), &exists).Close()
 	assert.False(t, exists)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, data1))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, data1))
 	assert.False(t, exists)
 	assert.NoError(t, err)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, data2))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, data2))
 	assert.False(t, exists)
 	assert.NoError(t, err)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, tc))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, tc))
 	assert.False(t, exists)
 	assert.NoError(t, err)
+	exists, err = utils.Exists(testData.WorkPath)
+	assert.False(t, exists, "working directory must be removed")
+	assert.NoError(t, err)
 	assert.NoError(t, downloads[0].Error)
 }
 
@@ -160,41 +167,42 @@ func TestCleaningWhenSendFailed(t *testing.T) {
 	//arrange
 	err = copyToRemote(testData.SftpCoba, testedEdt)
 	assert.NoError(t, err)
-	err = copyToLocal(testData.OutPath, testedZip, data1, data2, tc)
+	err = copyToLocal(testData.WorkPath, testedZip, data1, data2, tc)
 	assert.NoError(t, err)
 	config, err = conf.NewFactory().Get()
 	assert.NoError(t, err)
-	sftpClient, err := NewFactory(ClientOptions{config}).Get()
+	sftpClient, err := NewFactory(ClientOptions{SftpConfig: config}).Get()
 	assert.NoError(t, err)
 
 	downloads := []*structs.DownloadInfo{
 		{
 			Error:           errors.New("fake error"),
-			DestinationPath: path.Join(path.Join(testData.OutPath, testedZip)),
+			WorkDir:         testData.WorkPath,
+			DestinationPath: path.Join(path.Join(testData.WorkPath, testedZip)),
 			SourcePath:      path.Join(testData.SftpCoba, testedEdt),
 			Unzipped: []string{
-				path.Join(testData.OutPath, data1),
-				path.Join(testData.OutPath, data2),
-				path.Join(testData.OutPath, tc),
+				path.Join(testData.WorkPath, data1),
+				path.Join(testData.WorkPath, data2),
+				path.Join(testData.WorkPath, tc),
 			},
 			ResponsePath:       "",
 			SourcePathOriginal: path.Join(testData.SftpCoba, testedZip),
 		},
 	}
 	//act
-	err = sftpClient.Clean(downloads)
+	err = sftpClient.Clean(context.Background(), downloads)
 	//assert
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(downloads))
 	utils.CreateClient().Exists(filepath.Join(testData.SftpCoba, testedEdt), &exists).Close()
 	assert.True(t, exists)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, data1))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, data1))
 	assert.False(t, exists)
 	assert.NoError(t, err)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, data2))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, data2))
 	assert.False(t, exists)
 	assert.NoError(t, err)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, tc))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, tc))
 	assert.False(t, exists)
 	assert.NoError(t, err)
 	assert.Error(t, downloads[0].Error)
@@ -208,17 +216,18 @@ func TestCleaningWhenUnzipFailed(t *testing.T) {
 	//arrange
 	err = copyToRemote(testData.SftpCoba, testedEdt)
 	assert.NoError(t, err)
-	err = copyToLocal(testData.OutPath, testedZip)
+	err = copyToLocal(testData.WorkPath, testedZip)
 	assert.NoError(t, err)
 	config, err = conf.NewFactory().Get()
 	assert.NoError(t, err)
-	sftpClient, err := NewFactory(ClientOptions{config}).Get()
+	sftpClient, err := NewFactory(ClientOptions{SftpConfig: config}).Get()
 	assert.NoError(t, err)
 
 	downloads := []*structs.DownloadInfo{
 		{
 			Error:              errors.New("fake error"),
-			DestinationPath:    path.Join(path.Join(testData.OutPath, testedZip)),
+			WorkDir:            testData.WorkPath,
+			DestinationPath:    path.Join(path.Join(testData.WorkPath, testedZip)),
 			SourcePath:         path.Join(testData.SftpCoba, testedEdt),
 			Unzipped:           []string{},
 			ResponsePath:       "",
@@ -226,7 +235,7 @@ func TestCleaningWhenUnzipFailed(t *testing.T) {
 		},
 	}
 	//act
-	err = sftpClient.Clean(downloads)
+	err = sftpClient.Clean(context.Background(), downloads)
 	//assert
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(downloads))
@@ -234,13 +243,13 @@ func TestCleaningWhenUnzipFailed(t *testing.T) {
 	assert.False(t, exists)
 	utils.CreateClient().Exists(filepath.Join(testData.SftpCoba, testedEdt), &exists).Close()
 	assert.True(t, exists)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, data1))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, data1))
 	assert.False(t, exists)
 	assert.NoError(t, err)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, data2))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, data2))
 	assert.False(t, exists)
 	assert.NoError(t, err)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, tc))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, tc))
 	assert.False(t, exists)
 	assert.NoError(t, err)
 	assert.Error(t, downloads[0].Error)
@@ -256,13 +265,14 @@ func TestCleaningWhenDownloadFailed(t *testing.T) {
 	assert.NoError(t, err)
 	config, err = conf.NewFactory().Get()
 	assert.NoError(t, err)
-	sftpClient, err := NewFactory(ClientOptions{config}).Get()
+	sftpClient, err := NewFactory(ClientOptions{SftpConfig: config}).Get()
 	assert.NoError(t, err)
 
 	downloads := []*structs.DownloadInfo{
 		{
 			Error:              errors.New("fake error"),
-			DestinationPath:    path.Join(path.Join(testData.OutPath, testedZip)),
+			WorkDir:            testData.WorkPath,
+			DestinationPath:    path.Join(path.Join(testData.WorkPath, testedZip)),
 			SourcePath:         path.Join(testData.SftpCoba, testedEdt),
 			Unzipped:           []string{},
 			ResponsePath:       "",
@@ -270,7 +280,7 @@ func TestCleaningWhenDownloadFailed(t *testing.T) {
 		},
 	}
 	//act
-	err = sftpClient.Clean(downloads)
+	err = sftpClient.Clean(context.Background(), downloads)
 	//assert
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(downloads))
@@ -278,13 +288,13 @@ func TestCleaningWhenDownloadFailed(t *testing.T) {
 	assert.False(t, exists)
 	utils.CreateClient().Exists(filepath.Join(testData.SftpCoba, testedEdt), &exists).Close()
 	assert.True(t, exists)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, data1))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, data1))
 	assert.False(t, exists)
 	assert.NoError(t, err)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, data2))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, data2))
 	assert.False(t, exists)
 	assert.NoError(t, err)
-	exists, err = utils.Exists(filepath.Join(testData.OutPath, tc))
+	exists, err = utils.Exists(filepath.Join(testData.WorkPath, tc))
 	assert.False(t, exists)
 	assert.NoError(t, err)
 	assert.Error(t, downloads[0].Error)
@@ -303,11 +313,14 @@ func copyToRemote(remoteDir string, filesNames ...string) error {
 }
 
 func copyToLocal(outputDir string, filesNames ...string) error {
-	if err := utils.RemoveAllExcept(filepath.Join(outputDir, ".gitkeep")); err != nil {
+	if err := os.RemoveAll(outputDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
 		return err
 	}
 	for _, f := range filesNames {
-		if err := copyFileContents(filepath.Join(testData.InPath, f), filepath.Join(testData.OutPath, f)); err != nil {
+		if err := copyFileContents(filepath.Join(testData.InPath, f), filepath.Join(outputDir, f)); err != nil {
 			return err
 		}
 	}
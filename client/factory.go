@@ -1,21 +1,40 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"strings"
 
 	"github.com/Deutsche-Boerse/edt-sftp/client/sftp"
 	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
 	"github.com/Deutsche-Boerse/edt-sftp/conf"
+	"github.com/Deutsche-Boerse/edt-sftp/ledger"
+	"github.com/Deutsche-Boerse/edt-sftp/readiness"
+	"github.com/Deutsche-Boerse/edt-sftp/selector"
 )
 
-//Client
+//Client processes files of remote host. Methods stop processing further files once ctx is done
 type Client interface {
-	Download() ([]*structs.DownloadInfo, error)
-	Unzip(info []*structs.DownloadInfo) error
-	SendResponses(info []*structs.DownloadInfo) error
-	SendToEdt(info []*structs.DownloadInfo) error
-	Clean(info []*structs.DownloadInfo) error
+	Download(ctx context.Context) ([]*structs.DownloadInfo, error)
+	Decrypt(ctx context.Context, info []*structs.DownloadInfo) error
+	Unzip(ctx context.Context, info []*structs.DownloadInfo) error
+	Validate(ctx context.Context, info []*structs.DownloadInfo) error
+	SendResponses(ctx context.Context, info []*structs.DownloadInfo) error
+	SendToEdt(ctx context.Context, info []*structs.DownloadInfo) error
+	Clean(ctx context.Context, info []*structs.DownloadInfo) error
+	Rollback(info []*structs.DownloadInfo) error
+	Push(ctx context.Context) ([]*structs.UploadInfo, error)
+	Check() error
+	TestConnection(ctx context.Context) error
+	ProbeGateway(ctx context.Context) error
+	PurgeWorkDirs()
+	Strategy() (readiness.Strategy, error)
+	Planner() (*selector.Planner, error)
+	List(ctx context.Context) ([]selector.Candidate, error)
+	DryRun(ctx context.Context) ([]*structs.PlanInfo, error)
+	Requeue(ctx context.Context, remoteFile string) error
+	Fetch(ctx context.Context, remoteFile string) ([]*structs.DownloadInfo, error)
+	Resend(ctx context.Context, partner string, name string) (*structs.DownloadInfo, error)
 }
 
 type (
@@ -26,6 +45,12 @@ type (
 	//ClientOptions pass parameters for factory
 	ClientOptions struct {
 		SftpConfig *conf.SftpConfig
+		//Ledger records processing stages, nil disables it
+		Ledger *ledger.Ledger
+		//Readiness keeps readiness observations across runs, nil creates new strategy per run
+		Readiness readiness.Strategy
+		//Planner keeps partners served by fair planner across runs, nil creates new planner per run
+		Planner *selector.Planner
 	}
 
 	clientImpl struct {
@@ -42,7 +67,8 @@ func NewFactory(options ClientOptions) ClientFactory {
 
 func (c *clientImpl) Get() (Client, error) {
 	if strings.ToLower(c.options.SftpConfig.Type) == "sftp" {
-		return &sftp.Config{Config: c.options.SftpConfig}, nil
+		return &sftp.Config{Config: c.options.SftpConfig, Ledger: c.options.Ledger, Readiness: c.options.Readiness,
+			Plans: c.options.Planner}, nil
 	}
 	return nil, errors.New("not implemented client")
 }
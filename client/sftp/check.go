@@ -0,0 +1,127 @@
+package sftp
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/readiness"
+	"github.com/Deutsche-Boerse/edt-sftp/response"
+	"github.com/Deutsche-Boerse/edt-sftp/selector"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/rs/zerolog/log"
+)
+
+// error messages
+const (
+	ErrSrcPath = "SrcPath is not a folder "
+	ErrGateway = "api-gateway is not reachable "
+)
+
+//probeTimeout limits request probing api-gateway
+const probeTimeout = 10 * time.Second
+
+//Check validates configuration used by client without connecting to remote
+func (config *Config) Check() error {
+	if _, err := readiness.New(config.readinessOptions()); err != nil {
+		return errors.Wrap(err, "invalid readiness")
+	}
+	if _, err := selector.New(config.selectorOptions()); err != nil {
+		return errors.Wrap(err, "invalid file selection")
+	}
+	if _, err := selector.NewPlanner(config.planOptions()); err != nil {
+		return errors.Wrap(err, "invalid file ordering")
+	}
+	if _, err := config.dedupWindow(); err != nil {
+		return err
+	}
+	if config.Config.LedgerRetention != "" {
+		if _, err := time.ParseDuration(config.Config.LedgerRetention); err != nil {
+			return errors.Wrap(err, "invalid LedgerRetention "+config.Config.LedgerRetention)
+		}
+	}
+	if config.Config.AckTimeout != "" {
+		if _, err := time.ParseDuration(config.Config.AckTimeout); err != nil {
+			return errors.Wrap(err, ErrAckTimeout+config.Config.AckTimeout)
+		}
+	}
+	if _, err := response.NewGenerator(response.Format(config.Config.Acknowledge)); err != nil {
+		return errors.Wrap(err, "invalid Acknowledge")
+	}
+	for partner, format := range config.Config.PartnerAcknowledge {
+		if _, err := response.NewGenerator(response.Format(format)); err != nil {
+			return errors.Wrapf(err, "invalid acknowledge of partner '%s'", partner)
+		}
+	}
+	if config.Config.SendNack {
+		if _, err := response.NewGenerator(response.Format(config.Config.Nack)); err != nil {
+			return errors.Wrap(err, "invalid Nack")
+		}
+		for partner, format := range config.Config.PartnerNack {
+			if _, err := response.NewGenerator(response.Format(format)); err != nil {
+				return errors.Wrapf(err, "invalid nack of partner '%s'", partner)
+			}
+		}
+	}
+	if _, err := config.ackSigner(); err != nil {
+		return errors.Wrap(err, "invalid acknowledge signature")
+	}
+	if config.Config.PgpPrivateKeyFile != "" {
+		if _, err := config.privateKey(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//TestConnection dials remote, authenticates, checks SrcPath is a folder and probes ApiGatewayHost. Any HTTP
+//response of api-gateway means it is reachable
+func (config *Config) TestConnection(ctx context.Context) error {
+	connection, err := config.getConnection()
+	if err != nil {
+		return errors.Wrapf(err, "cannot connect to %s", config.Config.Host)
+	}
+	defer connection.Close()
+	log.Info().Msgf("%s connected to %s as %s", ident1, config.Config.Host, config.Config.User)
+	info, err := connection.Stat(config.Config.SrcPath)
+	if err != nil {
+		return errors.Wrapf(err, "cannot stat %s", config.Config.SrcPath)
+	}
+	if !info.IsDir() {
+		return errors.New(ErrSrcPath + config.Config.SrcPath)
+	}
+	log.Info().Msgf("%s found %s", ident1, config.Config.SrcPath)
+	if err = config.ProbeGateway(ctx); err != nil {
+		return err
+	}
+	log.Info().Msgf("%s api-gateway %s is reachable", ident1, config.Config.ApiGatewayHost)
+	return nil
+}
+
+//ProbeGateway sends HEAD request to ApiGatewayHost. Any HTTP response means api-gateway is reachable
+func (config *Config) ProbeGateway(ctx context.Context) error {
+	request, err := http.NewRequest(http.MethodHead, config.Config.ApiGatewayHost, nil)
+	if err != nil {
+		return errors.Wrap(err, ErrGateway+config.Config.ApiGatewayHost)
+	}
+	httpClient := http.Client{Timeout: probeTimeout}
+	resp, err := httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, ErrGateway+config.Config.ApiGatewayHost)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+//List returns files the next run would download, in order they would be processed
+func (config *Config) List(ctx context.Context) ([]selector.Candidate, error) {
+	var connection *sftp.Client
+	var err error
+	if connection, err = config.getConnection(); err != nil {
+		return nil, errors.Wrapf(err, "cannot connect to %s", config.Config.Host)
+	}
+	defer connection.Close()
+	return config.ready(ctx, connection)
+}
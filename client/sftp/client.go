@@ -2,6 +2,9 @@ package sftp
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/Deutsche-Boerse/edt-sftp/constants"
 	"github.com/rs/zerolog/log"
 	"io"
@@ -11,24 +14,52 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
 	"github.com/Deutsche-Boerse/edt-sftp/conf"
+	"github.com/Deutsche-Boerse/edt-sftp/ledger"
+	"github.com/Deutsche-Boerse/edt-sftp/manifest"
+	"github.com/Deutsche-Boerse/edt-sftp/metrics"
+	"github.com/Deutsche-Boerse/edt-sftp/pgp"
+	"github.com/Deutsche-Boerse/edt-sftp/readiness"
 	"github.com/Deutsche-Boerse/edt-sftp/response"
+	"github.com/Deutsche-Boerse/edt-sftp/selector"
+	"github.com/Deutsche-Boerse/edt-sftp/tracing"
 	"github.com/Deutsche-Boerse/edt-sftp/unzip"
+	"github.com/Deutsche-Boerse/edt-sftp/utils"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/pkg/errors"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
 const (
-	ErrDownloadsIsNil = "downloads is nil "
-	ErrEmptyZipFile   = "empty zip file "
+	ErrDownloadsIsNil   = "downloads is nil "
+	ErrEmptyZipFile     = "empty zip file "
+	ErrMissingManifest  = "missing manifest "
+	ErrDedupWindow      = "invalid DedupWindow "
+	ErrInvalidExtension = "invalid extension "
 )
 
+const (
+	unzippedDir            = "unzipped"
+	workDirPrefix          = "edt-work-"
+	trashSuffix            = ".trash"
+	staleWorkDirAge        = time.Hour
+	defaultStreamMaxMemory = 64 << 20
+	defaultStreamRunMemory = 256 << 20
+	//defaultStreamMaxUnzipped limits archive read in Streaming mode, so zip bomb is refused before it is inflated
+	defaultStreamMaxUnzipped = 512 << 20
+)
+
+//workDirName matches working directory edt-work-<file>-<random number> created by newWorkDir
+var workDirName = regexp.MustCompile(`^` + workDirPrefix + `.+-[0-9]+$`)
+
 const (
 	ident1 = " "
 	ident2 = "    + "
@@ -37,6 +68,14 @@ const (
 
 type Config struct {
 	Config *conf.SftpConfig
+	//Ledger records outcome of processing stages, nil disables it
+	Ledger *ledger.Ledger
+	//Readiness is kept across runs, so stable strategy remembers what it has seen. Nil creates new one per run
+	Readiness readiness.Strategy
+	//Plans is kept across runs, so fair planner remembers which partners it has served. Nil creates new one per run
+	Plans *selector.Planner
+	//streamed is size of archives the run keeps in memory in Streaming mode
+	streamed int64
 }
 
 func (config *Config) getConnection() (*sftp.Client, error) {
@@ -53,7 +92,7 @@ func (config *Config) getConnection() (*sftp.Client, error) {
 }
 
 //Download - downloads files from remote to local
-func (config *Config) Download() ([]*structs.DownloadInfo, error) {
+func (config *Config) Download(ctx context.Context) ([]*structs.DownloadInfo, error) {
 
 	var downloads []*structs.DownloadInfo
 	var connection *sftp.Client
@@ -64,99 +103,646 @@ func (config *Config) Download() ([]*structs.DownloadInfo, error) {
 		return []*structs.DownloadInfo{}, err
 	}
 	defer connection.Close()
+	//closing connection interrupts file being copied when ctx is done
+	copied := make(chan struct{})
+	defer close(copied)
+	go func() {
+		select {
+		case <-ctx.Done():
+			connection.Close()
+		case <-copied:
+		}
+	}()
+	purgeWorkDirs(config.Config.DstPath)
+
+	var window time.Duration
+	if window, err = config.dedupWindow(); err != nil {
+		log.Error().Err(err).Msg("invalid deduplication configuration")
+		return []*structs.DownloadInfo{}, err
+	}
+	var planned []selector.Candidate
+	if planned, err = config.ready(ctx, connection); err != nil {
+		return []*structs.DownloadInfo{}, err
+	}
+	for _, candidate := range planned {
+		//files downloaded so far are returned, the rest is picked up by the next run
+		if err = ctx.Err(); err != nil {
+			return downloads, err
+		}
+		partner := config.partner(candidate.Path)
+		_, span := tracing.Start(ctx, metrics.StageDownload, tracing.File(partner, candidate.Path, candidate.Size)...)
+		download := config.download(connection, candidate, window)
+		tracing.End(span, download.Error)
+		metrics.File(download.Partner, metrics.StageDownload, download.Error)
+		if download.Error == nil {
+			metrics.Transferred(download.Partner, metrics.DirectionDownload, download.Size)
+		}
+		downloads = append(downloads, download)
+	}
+	return downloads, nil
+}
+
+//download copies candidate, archives it and looks it up in ledger; failure is kept in Error of download
+func (config *Config) download(connection *sftp.Client, candidate selector.Candidate, window time.Duration) *structs.DownloadInfo {
+	downloadInfo, err := config.processDownload(connection, candidate)
+	if err != nil {
+		downloadInfo.Error = err
+		log.Error().Err(err).Msgf("failed downloading %s", candidate.Path)
+		return &downloadInfo
+	}
+	log.Info().Msgf("%s copied %s", ident1, downloadInfo.SourcePathOriginal)
+	if err = config.archive(&downloadInfo); err != nil {
+		log.Error().Err(err).Msgf("cannot archive %s", downloadInfo.SourcePathOriginal)
+	}
+	config.resume(&downloadInfo, window)
+	return &downloadInfo
+}
+
+//ready walks SrcPath and returns files ready for download in order they are processed, capped by MaxFiles and MaxBytes
+func (config *Config) ready(ctx context.Context, connection *sftp.Client) ([]selector.Candidate, error) {
+	var err error
+	var strategy readiness.Strategy
+	if strategy, err = config.Strategy(); err != nil {
+		log.Error().Err(err).Msg("invalid readiness configuration")
+		return nil, err
+	}
+	fs := &remoteFS{connection}
+	var sel *selector.Selector
+	if sel, err = selector.New(config.selectorOptions()); err != nil {
+		log.Error().Err(err).Msg("invalid file selection configuration")
+		return nil, err
+	}
+
+	var planner *selector.Planner
+	if planner, err = config.Planner(); err != nil {
+		log.Error().Err(err).Msg("invalid file ordering configuration")
+		return nil, err
+	}
 
+	//all ready files are collected first, so they can be ordered and capped before anything is downloaded
+	var candidates []selector.Candidate
+	//files left renamed to .edt by failed or interrupted runs
+	stuck := make(map[string]int)
+	readiness.Begin(strategy)
 	fileInfoWalker := connection.Walk(config.Config.SrcPath)
 	for {
 		if processed := !fileInfoWalker.Step(); processed {
 			break
 		}
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		currentFile := fileInfoWalker.Path()
+		rel := config.relative(currentFile)
 		var info os.FileInfo
 		if info = fileInfoWalker.Stat(); info.IsDir() {
+			//excluded folders, folders outside of Subfolders and folders deeper than MaxDepth are not listed at all
+			if rel != "." && sel.SkipDir(rel) {
+				fileInfoWalker.SkipDir()
+			}
 			continue
 		}
+		if strings.HasSuffix(currentFile, constants.EDT) {
+			stuck[config.partner(currentFile)]++
+		}
 
-		//We cannot download file in the middle of uploading so zero len file <filename>_0 must exists
-		if ok, err := path.Match(strings.ToLower(config.Config.FileMask), strings.ToLower(info.Name())); err != nil {
-			log.Error().Err(err).Msgf("cannot match '%s' with '%s'", strings.ToLower(info.Name()), config.Config.FileMask)
-			return downloads, err
-		} else if !ok {
+		//OpenPGP deliveries are matched without .pgp / .gpg extension
+		if !sel.Match(trimPgpExt(rel)) {
 			continue
 		}
-		currentFile := fileInfoWalker.Path()
-		// _0 file doesn't exist
-		if _, err := connection.Stat(currentFile + config.Config.ZeroLenFileSuffix); err != nil {
+		//We cannot download file in the middle of uploading, i.e. zero len file <filename>_0 must exists
+		if ready, err := strategy.Ready(fs, currentFile, info); err != nil {
+			log.Error().Err(err).Msgf("cannot check readiness of %s", currentFile)
 			continue
-		}
-
-		var downloadInfo structs.DownloadInfo
-		downloadInfo, err = config.processDownload(connection, currentFile)
-		downloads = append(downloads, &downloadInfo)
-		if err != nil {
-			downloadInfo.Error = err
-			log.Error().Err(err).Msgf("failed downloading %s", currentFile)
+		} else if !ready {
 			continue
 		}
-		log.Info().Msgf("%s copied %s", ident1, downloadInfo.SourcePathOriginal)
+		candidates = append(candidates, selector.Candidate{Path: currentFile, Rel: rel, Size: info.Size(),
+			ModTime: info.ModTime(), Markers: strategy.Markers(currentFile)})
 	}
-	return downloads, nil
+
+	metrics.Stuck(stuck)
+	for _, candidate := range candidates {
+		metrics.File(config.partner(candidate.Path), metrics.StageDiscover, nil)
+	}
+	planned := planner.Plan(candidates)
+	if len(planned) < len(candidates) {
+		log.Info().Msgf("%s%d of %d ready files postponed to the next run", ident1, len(candidates)-len(planned), len(candidates))
+	}
+	return planned, nil
 }
 
-func (config *Config) processDownload(connection *sftp.Client, currentFile string) (structs.DownloadInfo, error) {
+func (config *Config) processDownload(connection *sftp.Client, candidate selector.Candidate) (structs.DownloadInfo, error) {
+	currentFile := candidate.Path
 	downloadInfo := structs.DownloadInfo{}
 	downloadInfo.SourcePathOriginal = currentFile
+	downloadInfo.Size = candidate.Size
+	downloadInfo.ModTime = candidate.ModTime
+	downloadInfo.Partner = config.partner(currentFile)
 	var err error
 	//Renaming source file. When something breaks, we don't want to repeatedly grab that file
 	//instead of that, file stays in the source until issue is resolved
 	//the main reason is to prevent loosing files
-	downloadInfo.SourcePath = currentFile + constants.EDT
-	if err = connection.Rename(currentFile, downloadInfo.SourcePath); err != nil {
-		return downloadInfo, errors.Wrapf(err, "cannot rename %s to %s", currentFile, downloadInfo.SourcePath)
+	//SourcePath is set once the file is renamed, rollback renames it back
+	claimed := currentFile + constants.EDT
+	if err = connection.Rename(currentFile, claimed); err != nil {
+		return downloadInfo, errors.Wrapf(err, "cannot rename %s to %s", currentFile, claimed)
 	}
+	downloadInfo.SourcePath = claimed
 
 	// Copy
 	var srcFile *sftp.File
-	var dstFile *os.File
 	if srcFile, err = connection.Open(downloadInfo.SourcePath); err != nil {
 		return downloadInfo, errors.Wrapf(err, "cannot open connection for %s", downloadInfo.SourcePath)
 	}
 	defer srcFile.Close()
 
-	// Create the destination file
-	downloadInfo.DestinationPath = config.Config.DstPath + filepath.Base(currentFile)
-	if dstFile, err = os.Create(downloadInfo.DestinationPath); err != nil {
-		return downloadInfo, errors.Wrapf(err, "cannot create destination file %s", downloadInfo.DestinationPath)
+	hash := sha256.New()
+	if config.inMemory(connection, currentFile, srcFile) {
+		//nothing is written to DstPath; destination keeps only the name of delivered file
+		downloadInfo.DestinationPath = filepath.Base(currentFile)
+		if downloadInfo.Checksum, downloadInfo.SourceChecksumPath, err = readSidecar(connection, currentFile, constants.SHA256); err != nil {
+			return downloadInfo, err
+		}
+		if downloadInfo.Checksum != nil {
+			downloadInfo.ChecksumPath = downloadInfo.DestinationPath + constants.SHA256
+		}
+		var buffer bytes.Buffer
+		if _, err = srcFile.WriteTo(io.MultiWriter(&buffer, hash)); err != nil {
+			return downloadInfo, errors.Wrapf(err, "cannot read %s", srcFile.Name())
+		}
+		downloadInfo.Archive = buffer.Bytes()
+		config.streamed += int64(len(downloadInfo.Archive))
+	} else {
+		// Create the working directory; every download has its own working directory
+		// so content of different archives never collides
+		if downloadInfo.WorkDir, err = config.newWorkDir(filepath.Base(currentFile)); err != nil {
+			return downloadInfo, err
+		}
+		downloadInfo.DestinationPath = filepath.Join(downloadInfo.WorkDir, filepath.Base(currentFile))
+
+		//detached signature and checksum sidecar are optional and are downloaded next to delivered file
+		if downloadInfo.SignaturePath, downloadInfo.SourceSignaturePath, err =
+			downloadSidecar(connection, currentFile, downloadInfo.DestinationPath, constants.SIG); err != nil {
+			return downloadInfo, err
+		}
+		if downloadInfo.ChecksumPath, downloadInfo.SourceChecksumPath, err =
+			downloadSidecar(connection, currentFile, downloadInfo.DestinationPath, constants.SHA256); err != nil {
+			return downloadInfo, err
+		}
+
+		// Copy the file and compute its hash on the fly
+		var dstFile *os.File
+		if dstFile, err = os.Create(downloadInfo.DestinationPath); err != nil {
+			return downloadInfo, errors.Wrapf(err, "cannot create destination file %s", downloadInfo.DestinationPath)
+		}
+		defer dstFile.Close()
+		if _, err = srcFile.WriteTo(io.MultiWriter(dstFile, hash)); err != nil {
+			return downloadInfo, errors.Wrapf(err, "cannot write %s to destination %s", srcFile.Name(), dstFile.Name())
+		}
 	}
-	defer dstFile.Close()
+	downloadInfo.Hash = hex.EncodeToString(hash.Sum(nil))
+
+	//remove markers (i.e. zero len file) from source; removed markers are recreated by rollback
+	for _, marker := range candidate.Markers {
+		if err = connection.Remove(marker); err != nil {
+			return downloadInfo, errors.Wrapf(err, "cannot remove %s ", marker)
+		}
+		downloadInfo.Markers = append(downloadInfo.Markers, marker)
+	}
+	return downloadInfo, nil
+}
+
+//resume looks delivery up in ledger. Delivery which has been already sent is not sent again,
+//processing continues with response and clean. Delivery with content sent within window by other
+//delivery is marked as duplicate; zero window disables deduplication
+func (config *Config) resume(download *structs.DownloadInfo, window time.Duration) {
+	key := ledgerKey(download)
+	entry, err := config.Ledger.Get(key)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot read ledger for %s", download.SourcePathOriginal)
+	}
+	config.record(download, ledger.StageDownloaded, nil)
+	if entry.Done(ledger.StageSent) {
+		download.Sent = true
+		if entry.Receipt != "" {
+			download.Receipt = &structs.Receipt{ID: entry.Receipt}
+		}
+		log.Info().Msgf("%s already sent %s, resuming", ident2, path.Base(download.SourcePathOriginal))
+		return
+	}
+	if window <= 0 {
+		return
+	}
+	original, err := config.Ledger.Original(key, time.Now().Add(-window))
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot read ledger for %s", download.SourcePathOriginal)
+		return
+	}
+	if original == nil {
+		return
+	}
+	download.DuplicateOf = original.Key.Path
+	if err = config.Ledger.RecordDuplicate(key, download.Partner, original.Key); err != nil {
+		log.Error().Err(err).Msgf("cannot record duplicate %s in ledger", download.SourcePathOriginal)
+	}
+	log.Warn().Msgf("%s duplicate %s, content already sent as %s", ident2, download.SourcePathOriginal, original.Key.Path)
+}
+
+//dedupWindow returns look-back window of deduplication, zero if deduplication is disabled
+func (config *Config) dedupWindow() (time.Duration, error) {
+	if config.Config.DedupWindow == "" {
+		return 0, nil
+	}
+	window, err := time.ParseDuration(config.Config.DedupWindow)
+	if err != nil {
+		return 0, errors.Wrap(err, ErrDedupWindow+config.Config.DedupWindow)
+	}
+	if config.Config.LedgerPath == "" {
+		return 0, errors.New(ErrDedupWindow + "requires LedgerPath")
+	}
+	return window, nil
+}
+
+//record stores outcome of stage in ledger. Ledger failure doesn't fail the delivery. Deliveries which
+//were not completely downloaded have no hash and are not recorded
+func (config *Config) record(download *structs.DownloadInfo, stage string, stageErr error) {
+	if download.Hash == "" {
+		return
+	}
+	if err := config.Ledger.Record(ledgerKey(download), download.Partner, stage, stageErr); err != nil {
+		log.Error().Err(err).Msgf("cannot record %s of %s in ledger", stage, download.SourcePathOriginal)
+	}
+}
+
+//recordReceipt stores receipt id of gateway in ledger, so acknowledge of resumed delivery carries it too
+func (config *Config) recordReceipt(download *structs.DownloadInfo) {
+	if download.Hash == "" || download.Receipt.ID == "" {
+		return
+	}
+	if err := config.Ledger.RecordReceipt(ledgerKey(download), download.Receipt.ID); err != nil {
+		log.Error().Err(err).Msgf("cannot record receipt of %s in ledger", download.SourcePathOriginal)
+	}
+}
+
+func ledgerKey(download *structs.DownloadInfo) ledger.Key {
+	return ledger.Key{Path: download.SourcePathOriginal, Size: download.Size, ModTime: download.ModTime, Hash: download.Hash}
+}
+
+func (config *Config) selectorOptions() selector.Options {
+	return selector.Options{
+		FileMask:    config.Config.FileMask,
+		Include:     config.Config.Include,
+		Exclude:     config.Config.Exclude,
+		ExcludeDirs: config.Config.ExcludeDirs,
+		MaxDepth:    config.Config.MaxDepth,
+		Subfolders:  config.Config.Subfolders,
+	}
+}
+
+//Planner returns planner kept across runs or creates new one
+func (config *Config) Planner() (*selector.Planner, error) {
+	if config.Plans != nil {
+		return config.Plans, nil
+	}
+	return selector.NewPlanner(config.planOptions())
+}
+
+func (config *Config) planOptions() selector.PlanOptions {
+	return selector.PlanOptions{
+		Order:    config.Config.Order,
+		MaxFiles: config.Config.MaxFiles,
+		MaxBytes: config.Config.MaxBytes,
+		Fair:     config.Config.Fair,
+	}
+}
 
-	// Copy the file
+//Strategy returns readiness strategy kept across runs or creates new one
+func (config *Config) Strategy() (readiness.Strategy, error) {
+	if config.Readiness != nil {
+		return config.Readiness, nil
+	}
+	return readiness.New(config.readinessOptions())
+}
+
+func (config *Config) readinessOptions() readiness.Options {
+	return readiness.Options{
+		Strategy:  config.Config.Readiness,
+		Suffix:    config.Config.ZeroLenFileSuffix,
+		Extension: config.Config.ReadinessMarker,
+		Polls:     config.Config.ReadinessPolls,
+		MinAge:    config.Config.ReadinessMinAge,
+		Manifest:  config.Config.ReadinessManifest,
+	}
+}
+
+//remoteFS adapts sftp connection to readiness.FileSystem
+type remoteFS struct {
+	*sftp.Client
+}
+
+func (fs *remoteFS) ReadFile(p string) ([]byte, error) {
+	f, err := fs.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+//inMemory decides whether the archive is read into memory instead of working directory. Streaming is used
+//when enabled, the archive fits into StreamMaxMemory and doesn't need to be on disk for decryption or verification.
+//Archives are kept in memory until the run cleans them, once they would exceed StreamRunMemory the rest of
+//the run uses working directories
+func (config *Config) inMemory(connection *sftp.Client, currentFile string, srcFile *sftp.File) bool {
+	if !config.Config.Streaming || trimPgpExt(currentFile) != currentFile {
+		return false
+	}
+	if _, err := connection.Stat(currentFile + constants.SIG); err == nil {
+		return false
+	}
+	info, err := srcFile.Stat()
+	if err != nil {
+		return false
+	}
+	return config.fitsMemory(path.Base(currentFile), info.Size())
+}
+
+//fitsMemory returns true if archive of size fits into StreamMaxMemory and into what is left of StreamRunMemory
+func (config *Config) fitsMemory(name string, size int64) bool {
+	maxMemory := config.Config.StreamMaxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultStreamMaxMemory
+	}
+	if size > maxMemory {
+		return false
+	}
+	runMemory := config.Config.StreamRunMemory
+	if runMemory <= 0 {
+		runMemory = defaultStreamRunMemory
+	}
+	if config.streamed+size > runMemory {
+		log.Info().Msgf("%s memory of run is used up, %s is spilled into working directory", ident2, name)
+		return false
+	}
+	return true
+}
+
+//streamMaxUnzipped returns limit of uncompressed size of archive read in Streaming mode
+func (config *Config) streamMaxUnzipped() int64 {
+	if config.Config.StreamMaxUnzipped <= 0 {
+		return defaultStreamMaxUnzipped
+	}
+	return config.Config.StreamMaxUnzipped
+}
+
+//openUnzipped opens unzipped file either from archive in memory (streaming) or from working directory
+func openUnzipped(download *structs.DownloadInfo, file string) (io.ReadCloser, error) {
+	if download.Content == nil {
+		return os.Open(file)
+	}
+	return download.Content.Open(entryName(download, file))
+}
+
+//isUnzippedFile returns false for folders
+func isUnzippedFile(download *structs.DownloadInfo, file string) bool {
+	if download.Content != nil {
+		return download.Content.IsFile(entryName(download, file))
+	}
+	info, err := os.Stat(file)
+	return err == nil && !info.IsDir()
+}
+
+//entryName returns name of archive entry of unzipped file
+func entryName(download *structs.DownloadInfo, file string) string {
+	rel, err := filepath.Rel(contentDir(download), file)
+	if err != nil {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+//readChecksum reads checksum sidecar either from memory (streaming) or from working directory
+func readChecksum(download *structs.DownloadInfo) (manifest.Manifest, error) {
+	if download.Checksum == nil {
+		return manifest.Read(download.ChecksumPath)
+	}
+	sidecar, err := manifest.Parse(bytes.NewReader(download.Checksum))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read manifest %s", download.SourceChecksumPath)
+	}
+	return sidecar, nil
+}
+
+//readSidecar reads optional remote file <currentFile><ext> into memory.
+//Returns content and remote path or nil and empty string if sidecar doesn't exist
+func readSidecar(connection *sftp.Client, currentFile string, ext string) ([]byte, string, error) {
+	if _, err := connection.Stat(currentFile + ext); err != nil {
+		return nil, "", nil
+	}
+	content, err := (&remoteFS{connection}).ReadFile(currentFile + ext)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "cannot read %s", currentFile+ext)
+	}
+	return content, currentFile + ext, nil
+}
+
+//downloadSidecar downloads optional remote file <currentFile><ext> next to destination.
+//Returns local and remote path or empty strings if sidecar doesn't exist
+func downloadSidecar(connection *sftp.Client, currentFile string, destination string, ext string) (string, string, error) {
+	if _, err := connection.Stat(currentFile + ext); err != nil {
+		return "", "", nil
+	}
+	if err := copyFromRemote(connection, currentFile+ext, destination+ext); err != nil {
+		return "", "", err
+	}
+	return destination + ext, currentFile + ext, nil
+}
+
+func copyFromRemote(connection *sftp.Client, from string, to string) error {
+	srcFile, err := connection.Open(from)
+	if err != nil {
+		return errors.Wrapf(err, "cannot open connection for %s", from)
+	}
+	defer srcFile.Close()
+	dstFile, err := os.Create(to)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create destination file %s", to)
+	}
+	defer dstFile.Close()
 	if _, err = srcFile.WriteTo(dstFile); err != nil {
-		return downloadInfo, errors.Wrapf(err, "cannot write %s to destination %s", srcFile.Name(), dstFile.Name())
+		return errors.Wrapf(err, "cannot write %s to destination %s", from, to)
+	}
+	return nil
+}
+
+//partner is the first sub-folder of SrcPath the file was found in, empty for files directly in SrcPath
+func (config *Config) partner(remoteFile string) string {
+	segments := strings.Split(config.relative(remoteFile), "/")
+	if len(segments) < 2 {
+		return ""
 	}
+	return segments[0]
+}
 
-	//remove zero len file from source
-	if err = connection.Remove(downloadInfo.SourcePathOriginal + config.Config.ZeroLenFileSuffix); err != nil {
-		return downloadInfo, errors.Wrapf(err, "cannot remove %s ", downloadInfo.SourcePathOriginal+config.Config.ZeroLenFileSuffix)
+//relative returns path of remote file relative to SrcPath, "." for SrcPath itself
+func (config *Config) relative(remoteFile string) string {
+	rel := strings.TrimPrefix(path.Clean(remoteFile), path.Clean(config.Config.SrcPath))
+	rel = strings.TrimPrefix(rel, "/")
+	if rel == "" {
+		return "."
 	}
-	return downloadInfo, nil
+	return rel
+}
+
+//Decrypt decrypts OpenPGP deliveries (.pgp, .gpg) by our private key and verifies embedded or detached signatures
+//against keyring of the partner. Plain deliveries are passed unless PgpRequireSignature is set
+func (config *Config) Decrypt(ctx context.Context, downloads []*structs.DownloadInfo) error {
+	if downloads == nil {
+		return errors.New(ErrDownloadsIsNil)
+	}
+	var private openpgp.EntityList
+	spans := tracing.NewSequence(ctx, metrics.StageDecrypt)
+	defer spans.End()
+	for _, download := range downloads {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if download.Error != nil {
+			continue
+		}
+		spans.Next(&download.Error, tracing.File(download.Partner, download.SourcePathOriginal, download.Size)...)
+		encrypted := trimPgpExt(download.DestinationPath) != download.DestinationPath
+		if !encrypted && download.SignaturePath == "" && !config.Config.PgpRequireSignature {
+			continue
+		}
+		keyring, err := config.keyring(download.Partner, download.SignaturePath != "" || config.Config.PgpRequireSignature)
+		if err != nil {
+			download.Error = err
+			log.Error().Err(err).Msgf("cannot read keyring of partner '%s'", download.Partner)
+			continue
+		}
+		signed := false
+		if download.SignaturePath != "" {
+			if _, err = pgp.VerifyDetached(download.DestinationPath, download.SignaturePath, keyring); err != nil {
+				download.Error = err
+				log.Error().Err(err).Msgf("signature verification failed %s", path.Base(download.DestinationPath))
+				continue
+			}
+			signed = true
+			log.Info().Msgf("%s verified %s", ident2, path.Base(download.SignaturePath))
+		}
+		if encrypted {
+			if private == nil {
+				if private, err = config.privateKey(); err != nil {
+					download.Error = err
+					log.Error().Err(err).Msgf("cannot read pgp private key %s", config.Config.PgpPrivateKeyFile)
+					continue
+				}
+			}
+			decrypted := trimPgpExt(download.DestinationPath)
+			var result pgp.Result
+			if result, err = pgp.Decrypt(download.DestinationPath, decrypted, private, keyring); err != nil {
+				download.Error = err
+				log.Error().Err(err).Msgf("cannot decrypt %s", path.Base(download.DestinationPath))
+				continue
+			}
+			signed = signed || result.Signed
+			if err = os.Remove(download.DestinationPath); err != nil {
+				log.Error().Err(err).Msgf("cannot remove %s", download.DestinationPath)
+			}
+			download.DestinationPath = decrypted
+			log.Info().Msgf("%s decrypted %s", ident2, path.Base(decrypted))
+		}
+		if !signed && config.Config.PgpRequireSignature {
+			download.Error = errors.New(pgp.ErrMissingSignature + path.Base(download.SourcePathOriginal))
+			log.Error().Err(download.Error).Msg("signature verification failed")
+		}
+	}
+	return nil
+}
+
+//keyring reads public keyring of partner. When signature is not required, missing keyring is not an error;
+//message which turns out to be signed is then refused by pgp.Decrypt as signed by unknown key
+func (config *Config) keyring(partner string, required bool) (openpgp.EntityList, error) {
+	keyringPath := config.Config.PgpKeyring(partner)
+	if keyringPath == "" {
+		if !required {
+			return nil, nil
+		}
+		return nil, errors.New("PgpKeyringPath is not configured")
+	}
+	if !required {
+		if exists, _ := utils.Exists(keyringPath); !exists {
+			return nil, nil
+		}
+	}
+	return pgp.ReadKeyRing(keyringPath)
+}
+
+func (config *Config) privateKey() (openpgp.EntityList, error) {
+	if config.Config.PgpPrivateKeyFile == "" {
+		return nil, errors.New("PgpPrivateKeyFile is not configured")
+	}
+	passphrase, err := config.Config.PgpPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	return pgp.ReadPrivateKey(config.Config.PgpPrivateKeyFile, passphrase)
+}
+
+//trimPgpExt removes .pgp or .gpg extension
+func trimPgpExt(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == constants.PGP || ext == constants.GPG {
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+	return name
 }
 
 //Unzip source file locally
-func (config *Config) Unzip(downloads []*structs.DownloadInfo) error {
+func (config *Config) Unzip(ctx context.Context, downloads []*structs.DownloadInfo) error {
 	if downloads == nil {
 		return errors.New(ErrDownloadsIsNil)
 	}
+	spans := tracing.NewSequence(ctx, metrics.StageUnzip)
+	defer spans.End()
 	for _, download := range downloads {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		var unzipped []string
 		var err error
 		if download.Error != nil {
 			continue
 		}
+		spans.Next(&download.Error, tracing.File(download.Partner, download.SourcePathOriginal, download.Size)...)
 		if strings.ToLower(path.Ext(download.DestinationPath)) != constants.ZIP {
-			download.Error = errors.New("invalid extension")
+			download.Error = errors.New(ErrInvalidExtension + path.Base(download.DestinationPath))
 			log.Error().Msgf("%s %s", download.Error.Error(), download.DestinationPath)
 			continue
 		}
-		if unzipped, err = unzip.Unzip(download.DestinationPath, config.Config.DstPath); err != nil {
+		var password string
+		if password, err = config.Config.ZipPassword(download.Partner); err != nil {
+			download.Error = err
+			log.Error().Err(err).Msgf("cannot read zip password for partner '%s'", download.Partner)
+			continue
+		}
+		if download.Archive != nil {
+			//entries are inflated later while they are validated and sent
+			var content *unzip.Archive
+			content, err = unzip.OpenArchive(bytes.NewReader(download.Archive), int64(len(download.Archive)), password,
+				config.streamMaxUnzipped())
+			if err == nil {
+				download.Content = content
+				for _, name := range content.Names() {
+					unzipped = append(unzipped, filepath.Join(contentDir(download), name))
+				}
+			}
+		} else {
+			unzipped, err = unzip.UnzipWithPassword(download.DestinationPath, contentDir(download), password)
+		}
+		if err != nil {
 			download.Error = err
 			log.Error().Err(err).Msgf("cannot unzip file %s", download.DestinationPath)
 			continue
@@ -175,8 +761,130 @@ func (config *Config) Unzip(downloads []*structs.DownloadInfo) error {
 	return nil
 }
 
+//Validate checks deliveries against optional manifests before they are sent. Sidecar <file>.sha256 may list hash
+//of delivered file and/or hashes of archive content, manifest named ManifestName may be packed in the archive itself.
+//Manifest packed in the archive is removed from Unzipped so it is not sent further
+func (config *Config) Validate(ctx context.Context, downloads []*structs.DownloadInfo) error {
+	if downloads == nil {
+		return errors.New(ErrDownloadsIsNil)
+	}
+	spans := tracing.NewSequence(ctx, metrics.StageValidate)
+	defer spans.End()
+	for _, download := range downloads {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if download.Error != nil {
+			continue
+		}
+		spans.Next(&download.Error, tracing.File(download.Partner, download.SourcePathOriginal, download.Size)...)
+		if err := config.validate(download); err != nil {
+			download.Error = err
+			log.Error().Err(err).Msgf("invalid delivery %s", path.Base(download.SourcePathOriginal))
+		}
+	}
+	return nil
+}
+
+func (config *Config) validate(download *structs.DownloadInfo) error {
+	entries := map[string]string{}
+	var unzipped []string
+	for _, file := range download.Unzipped {
+		rel, err := filepath.Rel(contentDir(download), file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if config.Config.ManifestName != "" && strings.EqualFold(rel, config.Config.ManifestName) {
+			download.ManifestPath = file
+			continue
+		}
+		unzipped = append(unzipped, file)
+		if isUnzippedFile(download, file) {
+			entries[rel] = file
+		}
+	}
+	download.Unzipped = unzipped
+
+	if download.ChecksumPath == "" && download.ManifestPath == "" {
+		if config.Config.ManifestRequired {
+			return errors.New(ErrMissingManifest + path.Base(download.SourcePathOriginal))
+		}
+		return nil
+	}
+	//entries are hashed once and only when sidecar or manifest lists them
+	var files manifest.Manifest
+	hashed := func() (manifest.Manifest, error) {
+		var err error
+		if files == nil {
+			files, err = hashEntries(download, entries)
+		}
+		return files, err
+	}
+	if download.ChecksumPath != "" {
+		sidecar, err := readChecksum(download)
+		if err != nil {
+			return err
+		}
+		delivered := path.Base(download.SourcePathOriginal)
+		if expected, ok := sidecar[delivered]; ok {
+			if expected != download.Hash {
+				return &manifest.ValidationError{Mismatched: []string{delivered}}
+			}
+			delete(sidecar, delivered)
+		}
+		if len(sidecar) > 0 {
+			hashes, err := hashed()
+			if err != nil {
+				return err
+			}
+			if err = sidecar.Verify(hashes); err != nil {
+				return err
+			}
+		}
+		log.Info().Msgf("%s validated %s", ident2, path.Base(download.ChecksumPath))
+	}
+	if download.ManifestPath != "" {
+		f, err := openUnzipped(download, download.ManifestPath)
+		if err != nil {
+			return err
+		}
+		packed, err := manifest.Parse(f)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "cannot read manifest %s", download.ManifestPath)
+		}
+		hashes, err := hashed()
+		if err != nil {
+			return err
+		}
+		if err = packed.Verify(hashes); err != nil {
+			return err
+		}
+		log.Info().Msgf("%s validated %s", ident2, path.Base(download.ManifestPath))
+	}
+	return nil
+}
+
+//hashEntries hashes unzipped entries given by their path relative to content of delivery
+func hashEntries(download *structs.DownloadInfo, entries map[string]string) (manifest.Manifest, error) {
+	files := manifest.Manifest{}
+	for rel, file := range entries {
+		f, err := openUnzipped(download, file)
+		if err != nil {
+			return nil, err
+		}
+		files[rel], err = manifest.Hash(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
 //SendResponses sends response to source
-func (config *Config) SendResponses(downloads []*structs.DownloadInfo) error {
+func (config *Config) SendResponses(ctx context.Context, downloads []*structs.DownloadInfo) error {
 	var connection *sftp.Client
 	var err error
 	if downloads == nil {
@@ -187,52 +895,128 @@ func (config *Config) SendResponses(downloads []*structs.DownloadInfo) error {
 	}
 	defer connection.Close()
 
+	var signer response.Signer
+	if signer, err = config.ackSigner(); err != nil {
+		log.Error().Err(err).Msg("cannot create acknowledge signer")
+		return err
+	}
+	generators := map[string]*response.Generator{}
+	spans := tracing.NewSequence(ctx, metrics.StageRespond)
+	defer spans.End()
 	for _, download := range downloads {
-		if download.Error != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		//acknowledge already written by interrupted run is not written again
+		if download.Error != nil || download.ResponsePath != "" {
+			continue
+		}
+		spans.Next(&download.Error, tracing.File(download.Partner, download.SourcePathOriginal, download.Size)...)
+		var resp response.Acknowledge
+		if resp, err = config.acknowledge(download, generators); err != nil {
+			download.Error = err
+			log.Error().Err(err).Msgf("cannot create acknowledge of %s", download.SourcePathOriginal)
+			config.record(download, ledger.StageResponded, err)
 			continue
 		}
-		resp := response.GetAcknowledge(download.DestinationPath)
 		download.ResponsePath = filepath.Join(filepath.Dir(download.SourcePath), resp.Name)
 
 		var remoteResponse *sftp.File
 		if remoteResponse, err = connection.Create(download.ResponsePath); err != nil {
 			download.Error = err
 			log.Error().Msgf("cannot create .response at %s", download.ResponsePath)
+			config.record(download, ledger.StageResponded, err)
 			continue
 		}
 
 		if _, err := remoteResponse.Write(resp.Content); err != nil {
 			download.Error = err
 			log.Error().Err(err).Msgf("cannot write response to %s", download.ResponsePath)
+			config.record(download, ledger.StageResponded, err)
 			continue
 		}
 
 		if err = remoteResponse.Close(); err != nil {
 			download.Error = err
 			log.Error().Err(err).Msgf("cannot close %s", download.ResponsePath)
+			config.record(download, ledger.StageResponded, err)
 			continue
 		}
 
+		if signer != nil {
+			if download.ResponseSignaturePath, err = writeSignature(connection, download.ResponsePath, resp, signer); err != nil {
+				download.Error = err
+				log.Error().Err(err).Msgf("cannot sign %s", download.ResponsePath)
+				config.record(download, ledger.StageResponded, err)
+				continue
+			}
+		}
+
+		config.record(download, ledger.StageResponded, nil)
 		log.Info().Msgf("%s response %s", ident2, path.Base(download.ResponsePath))
 	}
 	return nil
 }
 
+//acknowledge creates acknowledge in format of partner; generators are cached by partner
+func (config *Config) acknowledge(download *structs.DownloadInfo, generators map[string]*response.Generator) (response.Acknowledge, error) {
+	generator, ok := generators[download.Partner]
+	if !ok {
+		var err error
+		if generator, err = response.NewGenerator(response.Format(config.Config.AcknowledgeOf(download.Partner))); err != nil {
+			return response.Acknowledge{}, err
+		}
+		generators[download.Partner] = generator
+	}
+	status := response.StatusOK
+	if download.DuplicateOf != "" {
+		status = response.StatusDuplicate
+	}
+	fields := response.Fields{
+		FileName: download.DestinationPath,
+		Partner:  download.Partner,
+		Size:     download.Size,
+		Hash:     download.Hash,
+		Status:   status,
+	}
+	if download.Receipt != nil {
+		fields.ReceiptID = download.Receipt.ID
+		fields.Accepted = download.Receipt.Accepted
+		fields.Messages = download.Receipt.Messages
+	}
+	return generator.Acknowledge(fields)
+}
+
 //SendToEdt sends files to ApiGateway
-func (config *Config) SendToEdt(downloads []*structs.DownloadInfo) error {
+func (config *Config) SendToEdt(ctx context.Context, downloads []*structs.DownloadInfo) error {
 	timeout := time.Duration(20 * time.Second)
 	httpClient := http.Client{Timeout: timeout}
+	spans := tracing.NewSequence(ctx, metrics.StageSend)
+	defer spans.End()
 	for _, download := range downloads {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		var resp *http.Response
 		var err error
 
 		if download.Error != nil {
 			continue
 		}
-		if resp, err = postMultipart(config.Config.ApiGatewayHost, download.Unzipped, httpClient); err != nil {
+		if download.Sent {
+			log.Info().Msgf("%s skipped sending %s, already sent", ident2, path.Base(download.DestinationPath))
+			continue
+		}
+		if download.DuplicateOf != "" {
+			log.Info().Msgf("%s skipped sending %s, duplicate of %s", ident2, path.Base(download.DestinationPath), download.DuplicateOf)
+			continue
+		}
+		fileCtx := spans.Next(&download.Error, tracing.File(download.Partner, download.SourcePathOriginal, download.Size)...)
+		if resp, err = postMultipart(fileCtx, config.Config.ApiGatewayHost, download, httpClient); err != nil {
 			log.Error().Err(err).Msgf("failed to request api-gateway %s", config.Config.ApiGatewayHost)
 			return err
 		}
+		metrics.GatewayResponse(resp.StatusCode)
 
 		body, err := ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
@@ -241,10 +1025,25 @@ func (config *Config) SendToEdt(downloads []*structs.DownloadInfo) error {
 			download.Error = err
 			continue
 		}
+		receipt, err := parseReceipt(body)
+		if err != nil {
+			log.Warn().Err(err).Msgf("cannot parse receipt of api-gateway for %s", path.Base(download.DestinationPath))
+		}
 		if resp.StatusCode >= http.StatusBadRequest {
-			message := string(body)
-			download.Error = errors.New(string(resp.StatusCode))
-			log.Error().Msgf("failed to request api-gateway %s", message)
+			download.Error = gatewayError(resp.StatusCode, body, receipt)
+			log.Error().Err(download.Error).Msgf("failed to request api-gateway %s", config.Config.ApiGatewayHost)
+		}
+		config.record(download, ledger.StageSent, download.Error)
+		if download.Error != nil {
+			continue
+		}
+		download.Sent = true
+		download.Receipt = receipt
+		if receipt != nil {
+			config.recordReceipt(download)
+			for _, message := range receipt.Messages {
+				log.Warn().Msgf("%s api-gateway %s: %s", ident2, path.Base(download.DestinationPath), message)
+			}
 		}
 		log.Info().Msgf("%s sent files from %s", ident2, path.Base(download.DestinationPath))
 	}
@@ -252,7 +1051,8 @@ func (config *Config) SendToEdt(downloads []*structs.DownloadInfo) error {
 }
 
 //Clean removes .zip file from Source an destination. If something breaks it cleans all except .edt file
-func (config *Config) Clean(downloads []*structs.DownloadInfo) error {
+//Clean is not interrupted by ctx, so processed files are never left half cleaned
+func (config *Config) Clean(ctx context.Context, downloads []*structs.DownloadInfo) error {
 
 	var connection *sftp.Client
 	var err error
@@ -263,26 +1063,36 @@ func (config *Config) Clean(downloads []*structs.DownloadInfo) error {
 		return err
 	}
 	defer connection.Close()
+	nackGenerators := map[string]*response.Generator{}
+	var signer response.Signer
+	if config.Config.SendNack {
+		if signer, err = config.ackSigner(); err != nil {
+			log.Error().Err(err).Msg("cannot create acknowledge signer, nacks are not signed")
+		}
+	}
+	spans := tracing.NewSequence(ctx, metrics.StageClean)
+	defer spans.End()
 	for _, download := range downloads {
+		spans.Next(&download.Error, tracing.File(download.Partner, download.SourcePathOriginal, download.Size)...)
 
-		//whether downloading passed or not we need remove file from source and zip from destination
-		if err = os.Remove(download.DestinationPath); err != nil {
+		//whether downloading passed or not we need remove working directory with zip and its content
+		if err = removeWorkDir(download.WorkDir); err != nil {
 			download.Error = err
-			log.Error().Err(err).Msgf("cannot remove %s", download.DestinationPath)
+			log.Error().Err(err).Msgf("cannot remove %s", download.WorkDir)
 			continue
 		}
+		download.Archive = nil
+		download.Content = nil
 		log.Info().Msgf("%s clean %s", ident1, path.Base(download.DestinationPath))
 		for _, unzipped := range download.Unzipped {
-			if err = os.Remove(unzipped); err != nil {
-				download.Error = err
-				log.Error().Err(err).Msgf("cannot remove %s", unzipped)
-				break
-			}
 			log.Info().Msgf("%s clean %s", ident3, path.Base(unzipped))
 		}
 
-		//if there are some errors we clean response file (if exists) and skip removing .edt file
+		//if there are some errors we tell partner why, clean response file (if exists) and skip removing .edt file
 		if download.Error != nil {
+			if config.Config.SendNack && rejectable(download.Error) {
+				config.sendNack(connection, download, nackGenerators, signer)
+			}
 			if download.ResponsePath != "" {
 				if err = connection.Remove(download.ResponsePath); err != nil {
 					download.Error = err
@@ -291,6 +1101,14 @@ func (config *Config) Clean(downloads []*structs.DownloadInfo) error {
 				}
 				log.Info().Msgf("%s clean %s", ident2, path.Base(download.ResponsePath))
 			}
+			if download.ResponseSignaturePath != "" {
+				if err = connection.Remove(download.ResponseSignaturePath); err != nil {
+					download.Error = err
+					log.Error().Err(err).Msgf("cannot remove %s", download.ResponseSignaturePath)
+					continue
+				}
+				log.Info().Msgf("%s clean %s", ident2, path.Base(download.ResponseSignaturePath))
+			}
 			continue
 		}
 
@@ -298,40 +1116,181 @@ func (config *Config) Clean(downloads []*structs.DownloadInfo) error {
 		if err = connection.Remove(download.SourcePath); err != nil {
 			download.Error = err
 			log.Error().Err(err).Msgf("cannot remove %s", download.SourcePath)
+			config.record(download, ledger.StageCleaned, err)
 			continue
 		}
+		config.record(download, ledger.StageCleaned, nil)
 		log.Info().Msgf("%s clean %s", ident2, path.Base(download.SourcePath))
+		for _, sidecar := range []string{download.SourceSignaturePath, download.SourceChecksumPath} {
+			if sidecar == "" {
+				continue
+			}
+			if err = connection.Remove(sidecar); err != nil {
+				download.Error = err
+				log.Error().Err(err).Msgf("cannot remove %s", sidecar)
+				break
+			}
+			log.Info().Msgf("%s clean %s", ident2, path.Base(sidecar))
+		}
 	}
 	return nil
 }
 
-// http://polyglot.ninja/golang-making-http-requests/
-func postMultipart(url string, files []string, client http.Client) (*http.Response, error) {
-	var requestBody bytes.Buffer
-	multipartWriter := multipart.NewWriter(&requestBody)
-	for index, file := range files {
-		err := createFormFile(index, file, multipartWriter)
-		if err != nil {
-			return nil, errors.Wrap(err, "unable to create multipart post request")
+//Rollback returns interrupted downloads to the state before they were picked up, so the next run processes them
+//again: working directory and written response are removed, .edt file gets its original name and removed markers
+//are recreated. Sent deliveries must be finished instead, they are sent again when ledger is disabled
+func (config *Config) Rollback(downloads []*structs.DownloadInfo) error {
+	var connection *sftp.Client
+	var err error
+	if downloads == nil {
+		return errors.New(ErrDownloadsIsNil)
+	}
+	if connection, err = config.getConnection(); err != nil {
+		return err
+	}
+	defer connection.Close()
+	var failed error
+	for _, download := range downloads {
+		if err = config.rollback(connection, download); err != nil {
+			failed = err
+			log.Error().Err(err).Msgf("cannot roll back %s", download.SourcePathOriginal)
+			continue
 		}
+		log.Info().Msgf("%s rolled back %s", ident1, path.Base(download.SourcePathOriginal))
 	}
-	err := multipartWriter.Close()
+	return failed
+}
+
+func (config *Config) rollback(connection *sftp.Client, download *structs.DownloadInfo) error {
+	if err := removeWorkDir(download.WorkDir); err != nil {
+		return err
+	}
+	download.Archive = nil
+	download.Content = nil
+	for _, remote := range []string{download.ResponsePath, download.ResponseSignaturePath} {
+		if remote == "" {
+			continue
+		}
+		if err := connection.Remove(remote); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "cannot remove %s", remote)
+		}
+	}
+	//file wasn't renamed, nothing else to roll back
+	if download.SourcePath == "" {
+		return nil
+	}
+	if err := connection.Rename(download.SourcePath, download.SourcePathOriginal); err != nil {
+		return errors.Wrapf(err, "cannot rename %s to %s", download.SourcePath, download.SourcePathOriginal)
+	}
+	for _, marker := range download.Markers {
+		if err := writeRemoteFile(connection, marker, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//newWorkDir creates working directory of file in DstPath. Prefix tells it from other folders of DstPath
+func (config *Config) newWorkDir(name string) (string, error) {
+	dir, err := ioutil.TempDir(config.Config.DstPath, workDirPrefix+name+"-")
 	if err != nil {
+		return "", errors.Wrapf(err, "cannot create working directory in %s", config.Config.DstPath)
+	}
+	return dir, nil
+}
+
+//contentDir is folder within working directory where archive is unzipped
+func contentDir(download *structs.DownloadInfo) string {
+	return filepath.Join(download.WorkDir, unzippedDir)
+}
+
+//removeWorkDir removes working directory. Directory is renamed first, so it disappears atomically
+//and leftovers of failed removal are purged by purgeWorkDirs on the next run
+func removeWorkDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	trash := dir + trashSuffix
+	if err := os.Rename(dir, trash); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return os.RemoveAll(trash)
+}
+
+//PurgeWorkDirs removes working directories left by previous process, i.e. killed in the middle of download.
+//Directories are recognized by name created by newWorkDir and must be older than staleWorkDirAge
+func (config *Config) PurgeWorkDirs() {
+	purgeWorkDirs(config.Config.DstPath)
+	dirs, err := ioutil.ReadDir(config.Config.DstPath)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot list %s", config.Config.DstPath)
+		return
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || !workDirName.MatchString(dir.Name()) || time.Since(dir.ModTime()) < staleWorkDirAge {
+			continue
+		}
+		stale := filepath.Join(config.Config.DstPath, dir.Name())
+		if err = removeWorkDir(stale); err != nil {
+			log.Error().Err(err).Msgf("cannot remove %s", stale)
+			continue
+		}
+		log.Info().Msgf("removed stale working directory %s", stale)
+	}
+}
+
+//purgeWorkDirs removes working directories which were not removed completely
+func purgeWorkDirs(dstPath string) {
+	trash, err := filepath.Glob(filepath.Join(dstPath, workDirPrefix+"*"+trashSuffix))
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot list %s", dstPath)
+		return
+	}
+	for _, dir := range trash {
+		if err = os.RemoveAll(dir); err != nil {
+			log.Error().Err(err).Msgf("cannot remove %s", dir)
+		}
+	}
+}
+
+//postMultipart sends unzipped files as multipart request. Body is written through pipe while it is sent,
+//so the request is never held in memory as a whole
+func postMultipart(ctx context.Context, url string, download *structs.DownloadInfo, client http.Client) (*http.Response, error) {
+	body, pipe := io.Pipe()
+	multipartWriter := multipart.NewWriter(pipe)
+	go func() {
+		for index, file := range download.Unzipped {
+			if err := createFormFile(index, download, file, multipartWriter); err != nil {
+				pipe.CloseWithError(errors.Wrap(err, "unable to create multipart post request"))
+				return
+			}
+		}
+		pipe.CloseWithError(multipartWriter.Close())
+	}()
+	request, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		body.Close()
 		return nil, err
 	}
-	return client.Post(url, multipartWriter.FormDataContentType(), &requestBody)
+	request.Header.Set("Content-Type", multipartWriter.FormDataContentType())
+	//gateway joins trace of the delivery
+	tracing.Inject(ctx, request.Header)
+	return client.Do(request.WithContext(ctx))
 }
 
-func createFormFile(index int, file string, writer *multipart.Writer) error {
-	fileWriter, err := writer.CreateFormFile("file_field"+string(index), filepath.Base(file))
+func createFormFile(index int, download *structs.DownloadInfo, file string, writer *multipart.Writer) error {
+	fileWriter, err := writer.CreateFormFile("file_field"+strconv.Itoa(index), filepath.Base(file))
 	if err != nil {
 		return err
 	}
-	f, err := os.Open(file)
-	defer f.Close()
+	f, err := openUnzipped(download, file)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 	_, err = io.Copy(fileWriter, f)
 	if err != nil {
 		return err
@@ -0,0 +1,175 @@
+package sftp
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/Deutsche-Boerse/edt-sftp/conf"
+	"github.com/Deutsche-Boerse/edt-sftp/unzip"
+	"github.com/Deutsche-Boerse/edt-sftp/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeWorkDirs(t *testing.T) {
+	//arrange
+	dstPath, err := ioutil.TempDir("", "dst")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dstPath)
+	old := time.Now().Add(-2 * staleWorkDirAge)
+	dirs := map[string]time.Time{
+		"edt-work-KV0011.zip-123":       old,
+		"edt-work-KV0012.zip-456":       time.Now(),
+		"edt-work-KV0013.zip-789.trash": time.Now(),
+		"KV0014.zip-123":                old,
+		"other.trash":                   old,
+		"archive":                       old,
+	}
+	for dir, modified := range dirs {
+		assert.NoError(t, os.Mkdir(filepath.Join(dstPath, dir), 0755))
+		assert.NoError(t, os.Chtimes(filepath.Join(dstPath, dir), modified, modified))
+	}
+	config := &Config{Config: &conf.SftpConfig{DstPath: dstPath}}
+
+	//act
+	config.PurgeWorkDirs()
+
+	//assert
+	for dir, kept := range map[string]bool{
+		"edt-work-KV0011.zip-123":       false,
+		"edt-work-KV0012.zip-456":       true,
+		"edt-work-KV0013.zip-789.trash": false,
+		"KV0014.zip-123":                true,
+		"other.trash":                   true,
+		"archive":                       true,
+	} {
+		exists, _ := utils.Exists(filepath.Join(dstPath, dir))
+		assert.Equal(t, kept, exists, dir)
+	}
+}
+
+func TestPostMultipartStreamsArchiveEntries(t *testing.T) {
+	//arrange
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for name, content := range map[string]string{"terms.xml": "<edt>terms</edt>", "prices.xml": "<edt>prices</edt>"} {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	content, err := unzip.OpenArchive(bytes.NewReader(archive.Bytes()), int64(archive.Len()), "", 1<<20)
+	assert.NoError(t, err)
+	download := &structs.DownloadInfo{Content: content}
+	for _, name := range content.Names() {
+		download.Unzipped = append(download.Unzipped, filepath.Join(unzippedDir, name))
+	}
+	received := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		assert.NoError(t, err)
+		for part, err := reader.NextPart(); err == nil; part, err = reader.NextPart() {
+			body, _ := ioutil.ReadAll(part)
+			received[part.FileName()] = string(body)
+		}
+	}))
+	defer server.Close()
+
+	//act
+	resp, err := postMultipart(context.Background(), server.URL, download, http.Client{})
+
+	//assert
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, map[string]string{"terms.xml": "<edt>terms</edt>", "prices.xml": "<edt>prices</edt>"}, received)
+}
+
+func TestStreamedArchivesFitRunMemory(t *testing.T) {
+	//arrange
+	config := &Config{Config: &conf.SftpConfig{StreamMaxMemory: 10, StreamRunMemory: 15}}
+
+	//act
+	first := config.fitsMemory("KV0011.zip", 10)
+	config.streamed += 10
+	second := config.fitsMemory("KV0012.zip", 5)
+	third := config.fitsMemory("KV0013.zip", 6)
+	tooBig := (&Config{Config: &conf.SftpConfig{StreamMaxMemory: 10}}).fitsMemory("KV0014.zip", 11)
+
+	//assert
+	assert.True(t, first)
+	assert.True(t, second)
+	assert.False(t, third, "run memory is used up")
+	assert.False(t, tooBig)
+}
+
+func TestValidateHashesEntriesOnlyWithSidecar(t *testing.T) {
+	//arrange
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	f, err := w.CreateHeader(&zip.FileHeader{Name: "terms.xml", Method: zip.Store})
+	assert.NoError(t, err)
+	f.Write([]byte("<edt>terms</edt>"))
+	assert.NoError(t, w.Close())
+	//entry which fails checksum once it is read
+	corrupted := bytes.Replace(archive.Bytes(), []byte("<edt>terms"), []byte("<edt>TERMS"), 1)
+	content, err := unzip.OpenArchive(bytes.NewReader(corrupted), int64(len(corrupted)), "", 0)
+	assert.NoError(t, err)
+	download := &structs.DownloadInfo{SourcePathOriginal: "/COBA/KV0011.zip", Content: content}
+	download.Unzipped = []string{filepath.Join(contentDir(download), "terms.xml")}
+	config := &Config{Config: &conf.SftpConfig{}}
+
+	//act
+	withoutSidecar := config.validate(download)
+	download.ChecksumPath, download.Checksum = "/COBA/KV0011.zip.sha256", []byte(strings.Repeat("0", 64)+"  terms.xml\n")
+	withSidecar := config.validate(download)
+
+	//assert
+	assert.NoError(t, withoutSidecar)
+	assert.EqualError(t, withSidecar, zip.ErrChecksum.Error())
+}
+
+func TestSrcFile(t *testing.T) {
+	config := &Config{Config: &conf.SftpConfig{SrcPath: "/home/edt/"}}
+	for remoteFile, expected := range map[string]string{
+		"/home/edt/COBA/KV0011.zip":        "/home/edt/COBA/KV0011.zip",
+		"/home/edt/COBA/KV0011.zip.edt":    "/home/edt/COBA/KV0011.zip",
+		"/home/edt/COBA/../KV0011.zip.edt": "/home/edt/KV0011.zip",
+		"/home/edt/../etc/passwd":          "",
+		"/home/edtx/KV0011.zip":            "",
+		"/home/edt":                        "",
+		"COBA/KV0011.zip":                  "",
+	} {
+		//arrange
+		//act
+		original, err := config.srcFile(remoteFile)
+
+		//assert
+		assert.Equal(t, expected, original, remoteFile)
+		assert.Equal(t, expected == "", err != nil, remoteFile)
+	}
+}
+
+func TestResendRefusesInvalidNames(t *testing.T) {
+	config := &Config{Config: &conf.SftpConfig{ArchivePath: "archive"}}
+	for _, name := range [][2]string{{"..", "KV0011.zip"}, {".", "KV0011.zip"}, {"", "KV0011.zip"}, {"COBA", ".."},
+		{"COBA/..", "KV0011.zip"}, {"COBA", `..\KV0011.zip`}} {
+		//arrange
+		//act
+		_, err := config.Resend(context.Background(), name[0], name[1])
+
+		//assert
+		assert.Error(t, err, name)
+		assert.Contains(t, err.Error(), ErrNotArchived, name)
+	}
+}
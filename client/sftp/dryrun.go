@@ -0,0 +1,157 @@
+package sftp
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/Deutsche-Boerse/edt-sftp/constants"
+	"github.com/Deutsche-Boerse/edt-sftp/outbox"
+	"github.com/Deutsche-Boerse/edt-sftp/response"
+	"github.com/Deutsche-Boerse/edt-sftp/selector"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+)
+
+//DryRun walks remote like Download, opens ready archives read-only to list their entries and returns renames,
+//sends, acknowledges, uploads and removals the run would make. Nothing is modified on remote, outbox is only
+//read and api-gateway is not called. Ledger is not consulted, so already sent deliveries are planned as new
+func (config *Config) DryRun(ctx context.Context) ([]*structs.PlanInfo, error) {
+	var connection *sftp.Client
+	var err error
+	if connection, err = config.getConnection(); err != nil {
+		return nil, errors.Wrapf(err, "cannot connect to %s", config.Config.Host)
+	}
+	defer connection.Close()
+	var signer response.Signer
+	if signer, err = config.ackSigner(); err != nil {
+		return nil, err
+	}
+	var planned []selector.Candidate
+	if planned, err = config.ready(ctx, connection); err != nil {
+		return nil, err
+	}
+	var plans []*structs.PlanInfo
+	generators := map[string]*response.Generator{}
+	nackGenerators := map[string]*response.Generator{}
+	for _, candidate := range planned {
+		if err = ctx.Err(); err != nil {
+			return plans, err
+		}
+		plans = append(plans, config.planDownload(connection, candidate, generators, nackGenerators, signer))
+	}
+	if config.Config.OutboxPath == "" {
+		return plans, nil
+	}
+	files, err := outbox.Scan(config.Config.OutboxPath)
+	if err != nil {
+		return plans, err
+	}
+	for _, file := range files {
+		plans = append(plans, config.planUpload(file))
+	}
+	return plans, nil
+}
+
+func (config *Config) planDownload(connection *sftp.Client, candidate selector.Candidate,
+	generators map[string]*response.Generator, nackGenerators map[string]*response.Generator, signer response.Signer) *structs.PlanInfo {
+	edt := candidate.Path + constants.EDT
+	plan := &structs.PlanInfo{Path: candidate.Path, Partner: config.partner(candidate.Path), Size: candidate.Size}
+	plan.Actions = append(plan.Actions, structs.Action{Op: structs.OpRename, Path: candidate.Path, Target: edt})
+	for _, marker := range candidate.Markers {
+		plan.Actions = append(plan.Actions, structs.Action{Op: structs.OpRemove, Path: marker})
+	}
+	var sidecars []string
+	for _, ext := range []string{constants.SIG, constants.SHA256} {
+		if _, err := connection.Stat(candidate.Path + ext); err == nil {
+			sidecars = append(sidecars, candidate.Path+ext)
+		}
+	}
+
+	//OpenPGP deliveries are decrypted before unzip, their entries cannot be listed without decryption
+	archive := trimPgpExt(candidate.Path)
+	download := &structs.DownloadInfo{SourcePathOriginal: candidate.Path, SourcePath: edt, Partner: plan.Partner,
+		Size: candidate.Size, DestinationPath: path.Base(archive)}
+	if strings.ToLower(path.Ext(archive)) != constants.ZIP {
+		plan.Error = errors.New(ErrInvalidExtension + path.Base(archive))
+	} else if archive != candidate.Path {
+		plan.Actions = append(plan.Actions, structs.Action{Op: structs.OpDecrypt, Path: path.Base(candidate.Path)})
+	} else {
+		plan.Entries, plan.Error = listArchive(connection, candidate.Path, candidate.Size)
+	}
+
+	dir := path.Dir(candidate.Path)
+	if plan.Error != nil {
+		//rejected file keeps .edt extension, partner gets nack if configured
+		if config.Config.SendNack {
+			download.Error = plan.Error
+			if nack, err := config.nack(download, nackGenerators); err == nil {
+				plan.Actions = append(plan.Actions, writeActions(path.Join(dir, nack.Name), signer)...)
+			}
+		}
+		return plan
+	}
+	plan.Actions = append(plan.Actions, structs.Action{Op: structs.OpSend, Path: path.Base(archive),
+		Target: config.Config.ApiGatewayHost})
+	ack, err := config.acknowledge(download, generators)
+	if err != nil {
+		plan.Error = err
+		return plan
+	}
+	plan.Actions = append(plan.Actions, writeActions(path.Join(dir, ack.Name), signer)...)
+	plan.Actions = append(plan.Actions, structs.Action{Op: structs.OpRemove, Path: edt})
+	for _, sidecar := range sidecars {
+		plan.Actions = append(plan.Actions, structs.Action{Op: structs.OpRemove, Path: sidecar})
+	}
+	return plan
+}
+
+func (config *Config) planUpload(file outbox.File) *structs.PlanInfo {
+	remote := path.Join(config.outboundDir(file.Partner), file.Name)
+	plan := &structs.PlanInfo{Path: file.Path, Partner: file.Partner, Size: file.Size}
+	plan.Actions = append(plan.Actions, structs.Action{Op: structs.OpUpload, Path: file.Path, Target: remote})
+	if config.Config.ZeroLenFileSuffix != "" {
+		plan.Actions = append(plan.Actions, structs.Action{Op: structs.OpWrite, Path: remote + config.Config.ZeroLenFileSuffix})
+	}
+	plan.Actions = append(plan.Actions, structs.Action{Op: structs.OpRename, Path: file.Path,
+		Target: filepath.Join(filepath.Dir(file.Path), outbox.SentDir, file.Name)})
+	return plan
+}
+
+//writeActions returns write of acknowledge and of its signature
+func writeActions(ackPath string, signer response.Signer) []structs.Action {
+	actions := []structs.Action{{Op: structs.OpWrite, Path: ackPath}}
+	if signer != nil {
+		actions = append(actions, structs.Action{Op: structs.OpWrite, Path: ackPath + signer.Extension()})
+	}
+	return actions
+}
+
+//listArchive lists entries of remote archive; remote file is opened read-only
+func listArchive(connection *sftp.Client, file string, size int64) ([]string, error) {
+	remote, err := connection.OpenFile(file, os.O_RDONLY)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open %s", file)
+	}
+	defer remote.Close()
+	reader, err := zip.NewReader(remote, size)
+	if err != nil {
+		return nil, err
+	}
+	var entries []string
+	for _, entry := range reader.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		entries = append(entries, entry.Name)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New(ErrEmptyZipFile)
+	}
+	return entries, nil
+}
@@ -0,0 +1,42 @@
+package sftp
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/Deutsche-Boerse/edt-sftp/conf"
+	"github.com/Deutsche-Boerse/edt-sftp/outbox"
+	"github.com/Deutsche-Boerse/edt-sftp/response"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanUpload(t *testing.T) {
+	//arrange
+	config := &Config{Config: &conf.SftpConfig{OutboundPath: "/outbound", ZeroLenFileSuffix: "_0"}}
+	local := filepath.Join("outbox", "COBA", "REPORT.csv")
+	file := outbox.File{Partner: "COBA", Name: "REPORT.csv", Path: local, Size: 12}
+
+	//act
+	plan := config.planUpload(file)
+
+	//assert
+	assert.Equal(t, []structs.Action{
+		{Op: structs.OpUpload, Path: local, Target: "/outbound/COBA/REPORT.csv"},
+		{Op: structs.OpWrite, Path: "/outbound/COBA/REPORT.csv_0"},
+		{Op: structs.OpRename, Path: local, Target: filepath.Join("outbox", "COBA", outbox.SentDir, "REPORT.csv")},
+	}, plan.Actions)
+	assert.Equal(t, "upload "+local+" -> /outbound/COBA/REPORT.csv", plan.Actions[0].String())
+}
+
+func TestWriteActionsWithSignature(t *testing.T) {
+	//act
+	actions := writeActions("/COBA/KV0011.zip.response", &response.Ed25519Signer{})
+
+	//assert
+	assert.Equal(t, []structs.Action{
+		{Op: structs.OpWrite, Path: "/COBA/KV0011.zip.response"},
+		{Op: structs.OpWrite, Path: "/COBA/KV0011.zip.response.sig"},
+	}, actions)
+}
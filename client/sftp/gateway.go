@@ -0,0 +1,53 @@
+package sftp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+)
+
+//maxErrorBody limits size of non JSON gateway reply in error message
+const maxErrorBody = 512
+
+//GatewayError is returned when edt-api-gateway refuses delivery
+type GatewayError struct {
+	StatusCode int
+	Messages   []string
+}
+
+func (e *GatewayError) Error() string {
+	return fmt.Sprintf("api-gateway responded %d: %s", e.StatusCode, strings.Join(e.Messages, "; "))
+}
+
+//parseReceipt parses JSON reply of gateway. Empty or non JSON reply returns nil receipt
+func parseReceipt(body []byte) (*structs.Receipt, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '{' {
+		return nil, nil
+	}
+	receipt := &structs.Receipt{}
+	if err := json.Unmarshal(body, receipt); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+//gatewayError creates error from refused request; validation messages of receipt are preferred to raw body
+func gatewayError(statusCode int, body []byte, receipt *structs.Receipt) *GatewayError {
+	gwErr := &GatewayError{StatusCode: statusCode}
+	if receipt != nil && len(receipt.Messages) > 0 {
+		gwErr.Messages = receipt.Messages
+		return gwErr
+	}
+	message := strings.TrimSpace(string(body))
+	if len(message) > maxErrorBody {
+		message = message[:maxErrorBody] + "..."
+	}
+	if message != "" {
+		gwErr.Messages = []string{message}
+	}
+	return gwErr
+}
@@ -0,0 +1,52 @@
+package sftp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReceipt(t *testing.T) {
+	//arrange
+	body := []byte(`{"receiptId": "R-2018-0001", "acceptedFiles": ["PPCZ01_160101-145332.xml"], "messages": ["TCCZ02_180808-145332.pdf: no ISIN"]}`)
+
+	//act
+	receipt, err := parseReceipt(body)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, "R-2018-0001", receipt.ID)
+	assert.Equal(t, []string{"PPCZ01_160101-145332.xml"}, receipt.Accepted)
+	assert.Equal(t, []string{"TCCZ02_180808-145332.pdf: no ISIN"}, receipt.Messages)
+}
+
+func TestParseNonJSONReceipt(t *testing.T) {
+	//arrange
+	//act
+	empty, emptyErr := parseReceipt([]byte("  "))
+	text, textErr := parseReceipt([]byte("OK"))
+	_, invalidErr := parseReceipt([]byte("{receiptId"))
+
+	//assert
+	assert.NoError(t, emptyErr)
+	assert.NoError(t, textErr)
+	assert.Nil(t, empty)
+	assert.Nil(t, text)
+	assert.Error(t, invalidErr)
+}
+
+func TestGatewayError(t *testing.T) {
+	//arrange
+	receipt, _ := parseReceipt([]byte(`{"messages": ["missing TC document"]}`))
+
+	//act
+	withReceipt := gatewayError(http.StatusUnprocessableEntity, nil, receipt)
+	withBody := gatewayError(http.StatusBadGateway, []byte("bad gateway\n"), nil)
+
+	//assert
+	assert.Equal(t, "api-gateway responded 422: missing TC document", withReceipt.Error())
+	assert.Equal(t, "api-gateway responded 502: bad gateway", withBody.Error())
+	assert.Equal(t, CodeGatewayRejected, errorCode(withReceipt))
+	assert.Equal(t, CodeProcessing, errorCode(withBody), "unavailable gateway is not partner's fault")
+}
@@ -0,0 +1,197 @@
+package sftp
+
+import (
+	"archive/zip"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/Deutsche-Boerse/edt-sftp/constants"
+	"github.com/Deutsche-Boerse/edt-sftp/ledger"
+	"github.com/Deutsche-Boerse/edt-sftp/manifest"
+	"github.com/Deutsche-Boerse/edt-sftp/pgp"
+	"github.com/Deutsche-Boerse/edt-sftp/response"
+	"github.com/Deutsche-Boerse/edt-sftp/unzip"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/rs/zerolog/log"
+)
+
+// error codes of negative acknowledges
+const (
+	CodeInvalidExtension = "INVALID_EXTENSION"
+	CodeSignature        = "SIGNATURE_INVALID"
+	CodeDecryption       = "DECRYPTION_FAILED"
+	CodeZipPassword      = "ZIP_PASSWORD_INVALID"
+	CodeZipCorrupted     = "ZIP_CORRUPTED"
+	CodeZipEmpty         = "ZIP_EMPTY"
+	CodeManifestMissing  = "MANIFEST_MISSING"
+	CodeManifestMismatch = "MANIFEST_MISMATCH"
+	CodeGatewayRejected  = "GATEWAY_REJECTED"
+	CodeProcessing       = "PROCESSING_FAILED"
+)
+
+//rejection derives error code and human readable reason from error of download. Local paths are removed from reason
+func rejection(download *structs.DownloadInfo) (string, string) {
+	err := download.Error
+	reason := err.Error()
+	if download.WorkDir != "" {
+		reason = strings.Replace(reason, download.WorkDir+string(filepath.Separator), "", -1)
+	}
+	reason = strings.Join(strings.Fields(reason), " ")
+	return errorCode(err), reason
+}
+
+//rejectable returns true for errors of delivery content, partner can fix them and send the delivery again.
+//Transient errors (SFTP I/O, unavailable gateway, timeouts) and errors of our configuration are not rejected,
+//the delivery stays renamed to .edt until it is requeued
+func rejectable(err error) bool {
+	return errorCode(err) != CodeProcessing
+}
+
+//errorCode classifies error of download by its cause; CodeProcessing is returned for errors partner cannot fix.
+//Local files, our keys, passwords and configuration are never fault of the delivery
+func errorCode(err error) string {
+	cause := errors.Cause(err)
+	message := err.Error()
+	if _, ok := cause.(*os.PathError); ok {
+		return CodeProcessing
+	}
+	if cause == unzip.ErrPasswordRequired ||
+		matches(message, pgp.ErrNoPrivateKey, pgp.ErrInvalidPassphrase, pgp.ErrReadKeyring, pgp.ErrEmptyKeyring) {
+		//encrypted archive of partner without password file, or our private key and keyrings are unusable
+		return CodeProcessing
+	}
+	if _, ok := cause.(*manifest.ValidationError); ok {
+		return CodeManifestMismatch
+	}
+	if gwErr, ok := cause.(*GatewayError); ok {
+		//unavailable or overloaded gateway is not fault of the delivery
+		if gwErr.StatusCode >= http.StatusInternalServerError || gwErr.StatusCode == http.StatusRequestTimeout ||
+			gwErr.StatusCode == http.StatusTooManyRequests {
+			return CodeProcessing
+		}
+		return CodeGatewayRejected
+	}
+	switch cause {
+	case unzip.ErrPassword:
+		return CodeZipPassword
+	case unzip.ErrChecksum, unzip.ErrAuthentication, unzip.ErrAesExtra, unzip.ErrAlgorithm, unzip.ErrTooBig, zip.ErrFormat, zip.ErrChecksum:
+		return CodeZipCorrupted
+	}
+	for code, messages := range map[string][]string{
+		CodeInvalidExtension: {ErrInvalidExtension},
+		CodeZipEmpty:         {ErrEmptyZipFile},
+		CodeManifestMissing:  {ErrMissingManifest},
+		CodeSignature:        {pgp.ErrMissingSignature, pgp.ErrUnknownSigner, pgp.ErrInvalidSignature},
+		CodeDecryption:       {pgp.ErrNotEncrypted, pgp.ErrArmor, pgp.ErrDecrypt},
+	} {
+		if matches(message, messages...) {
+			return code
+		}
+	}
+	return CodeProcessing
+}
+
+//matches returns true if error message starts with one of messages or wraps error starting with it
+func matches(message string, messages ...string) bool {
+	for _, m := range messages {
+		if strings.HasPrefix(message, m) || strings.Contains(message, ": "+m) {
+			return true
+		}
+	}
+	return false
+}
+
+//nack creates negative acknowledge in format of partner; generators are cached by partner
+func (config *Config) nack(download *structs.DownloadInfo, generators map[string]*response.Generator) (response.Acknowledge, error) {
+	generator, ok := generators[download.Partner]
+	if !ok {
+		format := response.Format(config.Config.NackOf(download.Partner))
+		if format.Name == "" {
+			format.Name = "{{.FileName}}" + constants.NACK
+		}
+		var err error
+		if generator, err = response.NewGenerator(format); err != nil {
+			return response.Acknowledge{}, err
+		}
+		generators[download.Partner] = generator
+	}
+	code, reason := rejection(download)
+	return generator.Acknowledge(response.Fields{
+		FileName: download.SourcePathOriginal,
+		Partner:  download.Partner,
+		Size:     download.Size,
+		Hash:     download.Hash,
+		Status:   response.StatusRejected,
+		Code:     code,
+		Reason:   reason,
+	})
+}
+
+//sendNack writes negative acknowledge next to rejected file. Failure is logged, the original error of download is kept
+func (config *Config) sendNack(connection *sftp.Client, download *structs.DownloadInfo, generators map[string]*response.Generator,
+	signer response.Signer) {
+	nack, err := config.nack(download, generators)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot create nack of %s", download.SourcePathOriginal)
+		return
+	}
+	nackPath := filepath.Join(filepath.Dir(download.SourcePathOriginal), nack.Name)
+	err = writeRemoteFile(connection, nackPath, nack.Content)
+	if err == nil && signer != nil {
+		_, err = writeSignature(connection, nackPath, nack, signer)
+	}
+	config.record(download, ledger.StageRejected, err)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot send nack of %s", download.SourcePathOriginal)
+		return
+	}
+	download.NackPath = nackPath
+	log.Info().Msgf("%s nack %s", ident2, path.Base(nackPath))
+}
+
+//ackSigner creates signer of acknowledges, nil if signing is not configured
+func (config *Config) ackSigner() (response.Signer, error) {
+	if config.Config.AckSignature == "" {
+		return nil, nil
+	}
+	passphrase, err := config.Config.PgpPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	return response.NewSigner(config.Config.AckSignature, config.Config.AckSigningKeyFile, passphrase)
+}
+
+//writeSignature writes signature of acknowledge written to remote ackPath and returns path of signature
+func writeSignature(connection *sftp.Client, ackPath string, ack response.Acknowledge, signer response.Signer) (string, error) {
+	signature, err := response.SignAcknowledge(ack, signer)
+	if err != nil {
+		return "", err
+	}
+	signaturePath := ackPath + signer.Extension()
+	if err = writeRemoteFile(connection, signaturePath, signature.Content); err != nil {
+		return "", err
+	}
+	return signaturePath, nil
+}
+
+//writeRemoteFile creates remote file with content
+func writeRemoteFile(connection *sftp.Client, file string, content []byte) error {
+	remote, err := connection.Create(file)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create %s", file)
+	}
+	if _, err = remote.Write(content); err != nil {
+		remote.Close()
+		return errors.Wrapf(err, "cannot write %s", file)
+	}
+	if err = remote.Close(); err != nil {
+		return errors.Wrapf(err, "cannot close %s", file)
+	}
+	return nil
+}
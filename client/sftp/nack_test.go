@@ -0,0 +1,98 @@
+package sftp
+
+import (
+	"archive/zip"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/Deutsche-Boerse/edt-sftp/manifest"
+	"github.com/Deutsche-Boerse/edt-sftp/pgp"
+	"github.com/Deutsche-Boerse/edt-sftp/unzip"
+
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodes(t *testing.T) {
+	for expected, err := range map[string]error{
+		CodeInvalidExtension: errors.New(ErrInvalidExtension + "KV0011.rar"),
+		CodeZipEmpty:         errors.New(ErrEmptyZipFile),
+		CodeManifestMissing:  errors.New(ErrMissingManifest + "KV0011.zip"),
+		CodeManifestMismatch: &manifest.ValidationError{Missing: []string{"terms.xml"}},
+		CodeSignature:        errors.Wrap(errors.New("openpgp: invalid signature"), pgp.ErrInvalidSignature+"KV0011.zip.sig"),
+		CodeDecryption:       errors.New(pgp.ErrNotEncrypted + "KV0011.zip.pgp"),
+		CodeZipPassword:      unzip.ErrPassword,
+		CodeZipCorrupted:     errors.Wrap(zip.ErrFormat, "cannot unzip"),
+		CodeProcessing:       errors.New("connection reset by peer"),
+	} {
+		//arrange
+		//act
+		code := errorCode(err)
+
+		//assert
+		assert.Equal(t, expected, code, err.Error())
+	}
+}
+
+func TestOnlyContentErrorsAreRejectable(t *testing.T) {
+	for err, expected := range map[error]bool{
+		errors.New(ErrInvalidExtension + "KV0011.rar"): true,
+		unzip.ErrPassword: true,
+		&GatewayError{StatusCode: 422, Messages: []string{"no ISIN"}}:             true,
+		&GatewayError{StatusCode: 503}:                                            false,
+		&GatewayError{StatusCode: 429}:                                            false,
+		errors.Wrap(errors.New("connection lost"), "cannot write KV0011.zip"):     false,
+		errors.Wrap(errors.New(pgp.ErrNoPrivateKey), "cannot decrypt KV0011.pgp"): false,
+	} {
+		//arrange
+		//act
+		rejected := rejectable(err)
+
+		//assert
+		assert.Equal(t, expected, rejected, err.Error())
+	}
+}
+
+func TestErrorsAreClassifiedByCause(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		code string
+	}{
+		{errors.Wrap(pgperrors.StructuralError("invalid packet"), pgp.ErrDecrypt+"KV0011.zip.pgp"), CodeDecryption},
+		{errors.Wrap(pgperrors.ErrKeyIncorrect, pgp.ErrDecrypt+"KV0011.zip.pgp"), CodeDecryption},
+		{errors.Wrap(errors.New("armor: invalid data"), pgp.ErrArmor+"KV0011.zip.asc"), CodeDecryption},
+		{errors.Wrap(pgperrors.SignatureError("hash tag doesn't match"), pgp.ErrInvalidSignature+"KV0011.zip.pgp"), CodeSignature},
+		{errors.Errorf("%s%X", pgp.ErrUnknownSigner, 0x1234), CodeSignature},
+		{unzip.ErrPasswordRequired, CodeProcessing},
+		{errors.Wrap(pgperrors.StructuralError("private key checksum failure"), pgp.ErrInvalidPassphrase), CodeProcessing},
+		{errors.Wrap(errors.New("openpgp: invalid data"), pgp.ErrReadKeyring+"/opt/edt/keys/COBA.asc"), CodeProcessing},
+		{errors.Wrap(&os.PathError{Op: "write", Path: "KV0011.zip", Err: syscall.ENOSPC}, pgp.ErrDecrypt+"KV0011.zip.pgp"), CodeProcessing},
+		{errors.Wrap(&os.PathError{Op: "open", Path: "COBA", Err: syscall.EACCES}, "can not read zip password from COBA"), CodeProcessing},
+	} {
+		//arrange
+		//act
+		code := errorCode(test.err)
+
+		//assert
+		assert.Equal(t, test.code, code, test.err.Error())
+		assert.Equal(t, test.code != CodeProcessing, rejectable(test.err), test.err.Error())
+	}
+}
+
+func TestRejectionHidesLocalPaths(t *testing.T) {
+	//arrange
+	download := &structs.DownloadInfo{
+		WorkDir: "/opt/edt/sftp/KV0011.zip-123",
+		Error:   errors.New("open /opt/edt/sftp/KV0011.zip-123/unzipped/terms.xml:\n no such file"),
+	}
+
+	//act
+	code, reason := rejection(download)
+
+	//assert
+	assert.Equal(t, CodeProcessing, code)
+	assert.Equal(t, "open unzipped/terms.xml: no such file", reason)
+}
@@ -0,0 +1,233 @@
+package sftp
+
+import (
+	"context"
+	"os"
+	"path"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/Deutsche-Boerse/edt-sftp/constants"
+	"github.com/Deutsche-Boerse/edt-sftp/ledger"
+	"github.com/Deutsche-Boerse/edt-sftp/manifest"
+	"github.com/Deutsche-Boerse/edt-sftp/metrics"
+	"github.com/Deutsche-Boerse/edt-sftp/outbox"
+	"github.com/Deutsche-Boerse/edt-sftp/tracing"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/rs/zerolog/log"
+)
+
+// error messages
+const (
+	ErrAckTimeout = "acknowledge timeout "
+)
+
+//Push uploads files waiting in OutboxPath/<partner> into OutboundPath/<partner> on remote. Zero len marker
+//<file>ZeroLenFileSuffix is created once the file is uploaded. Uploaded files are moved to .sent folder of partner
+//and acknowledges of partners are collected into .acks folder
+func (config *Config) Push(ctx context.Context) ([]*structs.UploadInfo, error) {
+	var uploads []*structs.UploadInfo
+	files, err := outbox.Scan(config.Config.OutboxPath)
+	if err != nil {
+		return uploads, err
+	}
+	var connection *sftp.Client
+	if connection, err = config.getConnection(); err != nil {
+		log.Error().Err(err).Msg("cannot establish connection")
+		return uploads, err
+	}
+	defer connection.Close()
+
+	for _, file := range files {
+		if err = ctx.Err(); err != nil {
+			return uploads, err
+		}
+		upload := &structs.UploadInfo{Partner: file.Partner, Name: file.Name, LocalPath: file.Path, Size: file.Size}
+		uploads = append(uploads, upload)
+		_, span := tracing.Start(ctx, metrics.StageUpload, tracing.File(file.Partner, file.Path, file.Size)...)
+		upload.Error = config.upload(connection, file, upload)
+		tracing.End(span, upload.Error)
+		metrics.File(file.Partner, metrics.StageUpload, upload.Error)
+		if upload.Error != nil {
+			log.Error().Err(upload.Error).Msgf("failed uploading %s", file.Path)
+			continue
+		}
+		metrics.Transferred(file.Partner, metrics.DirectionUpload, file.Size)
+		log.Info().Msgf("%s pushed %s", ident1, upload.RemotePath)
+	}
+	if err = config.collectAcks(ctx, connection); err != nil {
+		log.Error().Err(err).Msg("cannot collect acknowledges")
+	}
+	return uploads, nil
+}
+
+func (config *Config) upload(connection *sftp.Client, file outbox.File, upload *structs.UploadInfo) error {
+	remoteDir := config.outboundDir(file.Partner)
+	if err := connection.MkdirAll(remoteDir); err != nil {
+		return errors.Wrapf(err, "cannot create %s", remoteDir)
+	}
+	upload.RemotePath = path.Join(remoteDir, file.Name)
+	if err := copyToRemote(connection, file.Path, upload.RemotePath); err != nil {
+		return err
+	}
+	//partner must not pick the file up before it is completely uploaded
+	if config.Config.ZeroLenFileSuffix != "" {
+		upload.MarkerPath = upload.RemotePath + config.Config.ZeroLenFileSuffix
+		if err := writeRemoteFile(connection, upload.MarkerPath, nil); err != nil {
+			return err
+		}
+	}
+	var err error
+	if upload.SentPath, err = outbox.MarkSent(config.Config.OutboxPath, file); err != nil {
+		return errors.Wrapf(err, "cannot move %s to %s", file.Path, outbox.SentDir)
+	}
+	if config.Ledger != nil {
+		var info os.FileInfo
+		var key ledger.Key
+		if info, err = os.Stat(upload.SentPath); err == nil {
+			if key, err = config.outboundKey(file.Partner, file.Name, upload.SentPath, info.ModTime()); err == nil {
+				err = config.Ledger.Record(key, file.Partner, ledger.StageUploaded, nil)
+			}
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("cannot record upload of %s in ledger", upload.SentPath)
+		}
+	}
+	return nil
+}
+
+//collectAcks tracks files waiting for acknowledge of partner. Acknowledge <file>.response is downloaded from
+//outbound folder into .acks folder of outbox, parsed and removed from remote; the file is moved to .done or .rejected.
+//Files without acknowledge within AckTimeout are moved to .timeout and alerted, their late acknowledge is still collected.
+//Acknowledge naming other file rejects the file. Outcome is recorded in ledger
+func (config *Config) collectAcks(ctx context.Context, connection *sftp.Client) error {
+	var timeout time.Duration
+	if config.Config.AckTimeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(config.Config.AckTimeout); err != nil {
+			return errors.Wrap(err, ErrAckTimeout+config.Config.AckTimeout)
+		}
+	}
+	pending, err := outbox.Pending(config.Config.OutboxPath)
+	if err != nil {
+		return err
+	}
+	timedOut, err := outbox.TimedOut(config.Config.OutboxPath)
+	if err != nil {
+		return err
+	}
+	pending = append(pending, timedOut...)
+	fs := &remoteFS{connection}
+	for _, tracked := range pending {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		remoteAck := path.Join(config.outboundDir(tracked.Partner), tracked.Name+constants.RESPONSE)
+		content, err := fs.ReadFile(remoteAck)
+		if os.IsNotExist(err) {
+			if !tracked.TimedOut && tracked.Expired(timeout, time.Now()) {
+				config.expire(tracked)
+			}
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "cannot read %s", remoteAck)
+		}
+		if _, err = outbox.WriteAck(config.Config.OutboxPath, tracked.Partner, path.Base(remoteAck), content); err != nil {
+			return err
+		}
+		if err = connection.Remove(remoteAck); err != nil {
+			return errors.Wrapf(err, "cannot remove %s", remoteAck)
+		}
+		ack, err := outbox.ParseAck(content)
+		if err != nil {
+			//unreadable acknowledge is kept in .acks, the file is considered rejected
+			ack = outbox.Ack{File: tracked.Name, Status: outbox.StatusRejected, Reason: err.Error()}
+		} else if ack.File != tracked.Name {
+			//acknowledge of other file doesn't confirm delivery, it is kept in .acks and the file is rejected
+			ack = outbox.Ack{File: tracked.Name, Status: outbox.StatusRejected, Reason: outbox.ErrAckOfOther + ack.File}
+		}
+		config.recordOutbound(tracked, ackError(ack))
+		if _, err = outbox.Resolve(config.Config.OutboxPath, tracked, ack); err != nil {
+			return errors.Wrapf(err, "cannot resolve %s", tracked.Path)
+		}
+		if !ack.Accepted() {
+			log.Error().Msgf("%s partner %s rejected %s: %s", ident2, tracked.Partner, tracked.Name, ack.Reason)
+			continue
+		}
+		log.Info().Msgf("%s acknowledged %s", ident2, path.Base(remoteAck))
+	}
+	return nil
+}
+
+//expire moves file without acknowledge to .timeout and raises alert
+func (config *Config) expire(tracked outbox.Tracked) {
+	err := errors.Errorf("%s%s not acknowledged by %s since %s", ErrAckTimeout, tracked.Name, tracked.Partner,
+		tracked.SentAt.Format(time.RFC3339))
+	config.recordOutbound(tracked, err)
+	if _, moveErr := outbox.Expire(config.Config.OutboxPath, tracked); moveErr != nil {
+		log.Error().Err(moveErr).Msgf("cannot move %s to %s", tracked.Path, outbox.TimeoutDir)
+	}
+	log.Error().Str("alert", "ack_timeout").Str("partner", tracked.Partner).Msg(err.Error())
+}
+
+//recordOutbound records acknowledge stage of sent file in ledger
+func (config *Config) recordOutbound(tracked outbox.Tracked, stageErr error) {
+	if config.Ledger == nil {
+		return
+	}
+	key, err := config.outboundKey(tracked.Partner, tracked.Name, tracked.Path, tracked.SentAt)
+	if err == nil {
+		err = config.Ledger.Record(key, tracked.Partner, ledger.StageAcknowledged, stageErr)
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot record acknowledge of %s in ledger", tracked.Path)
+	}
+}
+
+//outboundKey is ledger key of sent file; remote path, size, time it was sent and hash identify the upload
+func (config *Config) outboundKey(partner string, name string, local string, sentAt time.Time) (ledger.Key, error) {
+	info, err := os.Stat(local)
+	if err != nil {
+		return ledger.Key{}, err
+	}
+	hash, err := manifest.HashFile(local)
+	if err != nil {
+		return ledger.Key{}, err
+	}
+	return ledger.Key{Path: path.Join(config.outboundDir(partner), name), Size: info.Size(), ModTime: sentAt, Hash: hash}, nil
+}
+
+func ackError(ack outbox.Ack) error {
+	if ack.Accepted() {
+		return nil
+	}
+	if ack.Reason == "" {
+		return errors.New(outbox.StatusRejected)
+	}
+	return errors.New(outbox.StatusRejected + ": " + ack.Reason)
+}
+
+//outboundDir is remote folder of partner the outbound files are uploaded into
+func (config *Config) outboundDir(partner string) string {
+	return path.Join(config.Config.OutboundPath, partner)
+}
+
+func copyToRemote(connection *sftp.Client, from string, to string) error {
+	srcFile, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	dstFile, err := connection.Create(to)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create %s", to)
+	}
+	if _, err = dstFile.ReadFrom(srcFile); err != nil {
+		dstFile.Close()
+		return errors.Wrapf(err, "cannot write %s to %s", from, to)
+	}
+	return dstFile.Close()
+}
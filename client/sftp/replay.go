@@ -0,0 +1,232 @@
+package sftp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/Deutsche-Boerse/edt-sftp/constants"
+	"github.com/Deutsche-Boerse/edt-sftp/readiness"
+	"github.com/Deutsche-Boerse/edt-sftp/selector"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// error messages
+const (
+	ErrNotArchived   = "delivery is not archived "
+	ErrNoArchivePath = "ArchivePath is not configured"
+	ErrOutsideSrc    = "file is not inside SrcPath "
+)
+
+//archive copies downloaded delivery and its sidecars into ArchivePath/<partner>, so it can be sent again by Resend.
+//Delivery of the same name replaces the archived one
+func (config *Config) archive(download *structs.DownloadInfo) error {
+	if config.Config.ArchivePath == "" {
+		return nil
+	}
+	dir := filepath.Join(config.Config.ArchivePath, download.Partner)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	name := path.Base(download.SourcePathOriginal)
+	var err error
+	if download.Archive != nil {
+		err = ioutil.WriteFile(filepath.Join(dir, name), download.Archive, 0644)
+	} else {
+		err = copyLocal(download.DestinationPath, filepath.Join(dir, name))
+	}
+	if err != nil {
+		return err
+	}
+	if download.Checksum != nil {
+		if err = ioutil.WriteFile(filepath.Join(dir, name+constants.SHA256), download.Checksum, 0644); err != nil {
+			return err
+		}
+	}
+	for _, sidecar := range []string{download.SignaturePath, download.ChecksumPath} {
+		if sidecar == "" || download.Checksum != nil && sidecar == download.ChecksumPath {
+			continue
+		}
+		if err = copyLocal(sidecar, filepath.Join(dir, name+filepath.Ext(sidecar))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//srcFile returns original name of remote file given to Requeue or Fetch; files outside SrcPath are refused
+func (config *Config) srcFile(remoteFile string) (string, error) {
+	original := strings.TrimSuffix(path.Clean(remoteFile), constants.EDT)
+	root := path.Clean(config.Config.SrcPath)
+	if root != "/" {
+		root += "/"
+	}
+	if !strings.HasPrefix(original, root) || original == root {
+		return "", errors.New(ErrOutsideSrc + remoteFile)
+	}
+	return original, nil
+}
+
+//validName returns true for name of single path element
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+//Requeue returns file which stays renamed to .edt to its original name and recreates its markers, so the next run
+//picks it up again. Both original and .edt path are accepted
+func (config *Config) Requeue(ctx context.Context, remoteFile string) error {
+	connection, err := config.getConnection()
+	if err != nil {
+		return errors.Wrapf(err, "cannot connect to %s", config.Config.Host)
+	}
+	defer connection.Close()
+	strategy, err := readiness.New(config.readinessOptions())
+	if err != nil {
+		return err
+	}
+	original, err := config.srcFile(remoteFile)
+	if err != nil {
+		return err
+	}
+	if err = connection.Rename(original+constants.EDT, original); err != nil {
+		return errors.Wrapf(err, "cannot rename %s to %s", original+constants.EDT, original)
+	}
+	for _, marker := range strategy.Markers(original) {
+		if err = writeRemoteFile(connection, marker, nil); err != nil {
+			return err
+		}
+	}
+	log.Info().Msgf("%s requeued %s", ident1, original)
+	return nil
+}
+
+//Fetch downloads single remote file regardless of file selection and readiness; file renamed to .edt by previous
+//run is renamed back first. Existing markers are removed like in Download
+func (config *Config) Fetch(ctx context.Context, remoteFile string) ([]*structs.DownloadInfo, error) {
+	connection, err := config.getConnection()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot connect to %s", config.Config.Host)
+	}
+	defer connection.Close()
+	strategy, err := readiness.New(config.readinessOptions())
+	if err != nil {
+		return nil, err
+	}
+	window, err := config.dedupWindow()
+	if err != nil {
+		return nil, err
+	}
+	original, err := config.srcFile(remoteFile)
+	if err != nil {
+		return nil, err
+	}
+	info, err := connection.Stat(original)
+	if os.IsNotExist(err) {
+		if err = connection.Rename(original+constants.EDT, original); err != nil {
+			return nil, errors.Wrapf(err, "cannot rename %s to %s", original+constants.EDT, original)
+		}
+		info, err = connection.Stat(original)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot stat %s", original)
+	}
+	var markers []string
+	for _, marker := range strategy.Markers(original) {
+		if _, err = connection.Stat(marker); err == nil {
+			markers = append(markers, marker)
+		}
+	}
+	candidate := selector.Candidate{Path: original, Rel: config.relative(original), Size: info.Size(),
+		ModTime: info.ModTime(), Markers: markers}
+	return []*structs.DownloadInfo{config.download(connection, candidate, window)}, nil
+}
+
+//Resend sends archived delivery of partner to api-gateway again. Delivery is decrypted, unzipped and validated
+//in its own working directory; nothing is written to remote and ledger isn't consulted
+func (config *Config) Resend(ctx context.Context, partner string, name string) (*structs.DownloadInfo, error) {
+	if config.Config.ArchivePath == "" {
+		return nil, errors.New(ErrNoArchivePath)
+	}
+	if !validName(partner) || !validName(name) {
+		return nil, errors.New(ErrNotArchived + path.Join(partner, name))
+	}
+	archived := filepath.Join(config.Config.ArchivePath, partner, name)
+	info, err := os.Stat(archived)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrNotArchived+path.Join(partner, name))
+	}
+	download := &structs.DownloadInfo{SourcePathOriginal: archived, Partner: partner, Size: info.Size(), ModTime: info.ModTime()}
+	if download.WorkDir, err = config.newWorkDir(name); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := removeWorkDir(download.WorkDir); err != nil {
+			log.Error().Err(err).Msgf("cannot remove %s", download.WorkDir)
+		}
+	}()
+	download.DestinationPath = filepath.Join(download.WorkDir, name)
+	if download.Hash, err = copyLocalHashed(archived, download.DestinationPath); err != nil {
+		return download, err
+	}
+	for _, ext := range []string{constants.SIG, constants.SHA256} {
+		if _, err = os.Stat(archived + ext); err != nil {
+			continue
+		}
+		if err = copyLocal(archived+ext, download.DestinationPath+ext); err != nil {
+			return download, err
+		}
+		if ext == constants.SIG {
+			download.SignaturePath = download.DestinationPath + ext
+		} else {
+			download.ChecksumPath = download.DestinationPath + ext
+		}
+	}
+	downloads := []*structs.DownloadInfo{download}
+	for _, stage := range []func(context.Context, []*structs.DownloadInfo) error{config.Decrypt, config.Unzip,
+		config.Validate, config.SendToEdt} {
+		if err = stage(ctx, downloads); err != nil {
+			return download, err
+		}
+	}
+	if download.Error == nil {
+		log.Info().Msgf("%s resent %s", ident1, path.Join(partner, name))
+	}
+	return download, nil
+}
+
+//copyLocal copies local file
+func copyLocal(from string, to string) error {
+	_, err := copyLocalHashed(from, to)
+	return err
+}
+
+//copyLocalHashed copies local file and returns its sha256
+func copyLocalHashed(from string, to string) (string, error) {
+	src, err := os.Open(from)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot create %s", to)
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(dst, hash), src); err != nil {
+		dst.Close()
+		return "", errors.Wrapf(err, "cannot copy %s to %s", from, to)
+	}
+	if err = dst.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
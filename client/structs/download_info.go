@@ -1,10 +1,35 @@
 package structs
 
+import (
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/unzip"
+)
+
 type DownloadInfo struct {
-	DestinationPath    string
-	SourcePath         string
-	SourcePathOriginal string
-	Unzipped           []string
-	ResponsePath       string
-	Error              error
+	WorkDir               string
+	DestinationPath       string
+	SourcePath            string
+	SourcePathOriginal    string
+	Partner               string
+	SignaturePath         string
+	SourceSignaturePath   string
+	ChecksumPath          string
+	Checksum              []byte
+	SourceChecksumPath    string
+	Markers               []string
+	ManifestPath          string
+	Size                  int64
+	ModTime               time.Time
+	Hash                  string
+	Sent                  bool
+	DuplicateOf           string
+	Unzipped              []string
+	Archive               []byte
+	Content               *unzip.Archive
+	Receipt               *Receipt
+	ResponsePath          string
+	ResponseSignaturePath string
+	NackPath              string
+	Error                 error
 }
@@ -0,0 +1,39 @@
+package structs
+
+import "fmt"
+
+// operations of dry run
+const (
+	OpRename  = "rename"
+	OpRemove  = "remove"
+	OpDecrypt = "decrypt"
+	OpSend    = "send"
+	OpWrite   = "write"
+	OpUpload  = "upload"
+)
+
+//Action is single step processing of file would make
+type Action struct {
+	Op     string
+	Path   string
+	Target string
+}
+
+func (a Action) String() string {
+	if a.Target == "" {
+		return fmt.Sprintf("%s %s", a.Op, a.Path)
+	}
+	return fmt.Sprintf("%s %s -> %s", a.Op, a.Path, a.Target)
+}
+
+//PlanInfo describes what processing of remote delivery or outbox file would do
+type PlanInfo struct {
+	Path    string
+	Partner string
+	Size    int64
+	//Entries of archive, nil if archive is encrypted by OpenPGP
+	Entries []string
+	Actions []Action
+	//Error is error processing would fail on
+	Error error
+}
@@ -0,0 +1,11 @@
+package structs
+
+//Receipt is JSON reply of edt-api-gateway to uploaded delivery
+type Receipt struct {
+	//ID is receipt identifier assigned by gateway
+	ID string `json:"receiptId"`
+	//Accepted lists names of accepted files
+	Accepted []string `json:"acceptedFiles"`
+	//Messages are validation messages
+	Messages []string `json:"messages"`
+}
@@ -0,0 +1,12 @@
+package structs
+
+type UploadInfo struct {
+	Partner    string
+	Name       string
+	LocalPath  string
+	SentPath   string
+	RemotePath string
+	MarkerPath string
+	Size       int64
+	Error      error
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/conf"
+	"github.com/Deutsche-Boerse/edt-sftp/constants"
+	"github.com/Deutsche-Boerse/edt-sftp/host2host"
+	"github.com/Deutsche-Boerse/edt-sftp/readiness"
+	"github.com/Deutsche-Boerse/edt-sftp/tracing"
+
+	"github.com/rs/zerolog/log"
+)
+
+const usage = `usage: edt-sftp [command] [-config file] [flags]
+
+commands:
+  run              downloads files by Cron until SIGTERM or SIGINT (default)
+  once             downloads files once and exits; with -dry-run only prints what would be done
+  validate         loads and checks configuration
+  test-connection  connects to Host, checks SrcPath and probes ApiGatewayHost
+  list             shows files the next download would pick up
+  requeue          renames remote -path back from .edt and recreates its markers
+  reprocess        processes remote -path regardless of its markers
+  resend           sends delivery -name of -partner archived in ArchivePath to api-gateway again
+
+exit codes: 0 ok, 1 connection failed, 2 invalid configuration, 3 some files failed, 4 invalid command line
+`
+
+//commands of command line
+var commands = map[string]func(app) int{
+	"run":             app.Run,
+	"once":            app.Once,
+	"validate":        app.Validate,
+	"test-connection": app.TestConnection,
+	"list":            app.List,
+	"requeue":         app.Requeue,
+	"reprocess":       app.Reprocess,
+	"resend":          app.Resend,
+}
+
+//Main parses command line, loads configuration and executes command. It returns exit code
+func (a app) Main(args []string) int {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n%s", name, usage)
+		return constants.ErrorUsage
+	}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flags.String("config", "", "path to configuration file, overrides "+conf.EnvPath)
+	switch name {
+	case "once":
+		flags.BoolVar(&a.dryRun, "dry-run", false, "print renames, sends, acknowledges, uploads and removals without doing them")
+	case "requeue", "reprocess":
+		flags.StringVar(&a.path, "path", "", "remote file, i.e. /home/COBA/KV0011.zip or /home/COBA/KV0011.zip.edt")
+	case "resend":
+		flags.StringVar(&a.partner, "partner", "", "partner of archived delivery")
+		flags.StringVar(&a.name, "name", "", "file name of archived delivery")
+	}
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage+"\nflags:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return constants.Ok
+		}
+		return constants.ErrorUsage
+	}
+	if (name == "requeue" || name == "reprocess") && a.path == "" || (name == "resend" && a.name == "") {
+		fmt.Fprintf(os.Stderr, "missing required flag of %s\n\n", name)
+		flags.Usage()
+		return constants.ErrorUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments %v\n\n", flags.Args())
+		flags.Usage()
+		return constants.ErrorUsage
+	}
+	if *configFile != "" {
+		os.Setenv(conf.EnvPath, *configFile)
+	}
+	con, err := conf.NewFactory().Get()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to read configuration")
+		return constants.ErrorConfiguration
+	}
+	config = con
+	shutdown, err := tracing.Setup(context.Background(), config.TraceExporter, config.TraceEndpoint)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid tracing configuration")
+		return constants.ErrorConfiguration
+	}
+	defer func() {
+		//spans still buffered are exported before exit
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("cannot export spans")
+		}
+	}()
+	return command(a)
+}
+
+//Once downloads files and pushes outbox once. SIGTERM or SIGINT cancels the run and rolls unfinished files back
+func (a app) Once() int {
+	if !oneShot("once") {
+		return constants.ErrorConfiguration
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if a.dryRun {
+		return dryRun(ctx)
+	}
+	code, err := download(ctx)
+	if err == nil {
+		return constants.Ok
+	}
+	log.Error().Err(err).Msg("download failed")
+	if code == constants.Ok {
+		return constants.ErrorProcessing
+	}
+	return code
+}
+
+//oneShot returns false if readiness is decided by polls of running service, command running once would
+//never find a file ready
+func oneShot(command string) bool {
+	if readiness.Polled(config.Readiness) {
+		log.Error().Msgf("%s cannot use %s readiness, files are ready only after ReadinessPolls polls of run command",
+			command, config.Readiness)
+		return false
+	}
+	return true
+}
+
+//dryRun prints plan of the run; files the run would reject are reported with their error
+func dryRun(ctx context.Context) int {
+	plans, err := host2host.DryRun(ctx, config)
+	if err != nil {
+		log.Error().Err(err).Msg("dry run failed")
+		return constants.ErrorEstablishedConnection
+	}
+	code := constants.Ok
+	for _, plan := range plans {
+		fmt.Printf("%s (%d bytes)\n", plan.Path, plan.Size)
+		if len(plan.Entries) > 0 {
+			fmt.Printf("  entries: %s\n", strings.Join(plan.Entries, ", "))
+		}
+		if plan.Error != nil {
+			fmt.Printf("  error: %s\n", plan.Error)
+			code = constants.ErrorProcessing
+		}
+		for _, action := range plan.Actions {
+			fmt.Printf("  %s\n", action)
+		}
+	}
+	if len(plans) == 0 {
+		fmt.Println("nothing to do")
+	}
+	return code
+}
+
+//Validate checks configuration of service and client
+func (app) Validate() int {
+	if _, _, err := schedule(config); err != nil {
+		log.Error().Err(err).Msg("invalid configuration")
+		return constants.ErrorConfiguration
+	}
+	if err := host2host.Check(config); err != nil {
+		log.Error().Err(err).Msg("invalid configuration")
+		return constants.ErrorConfiguration
+	}
+	log.Info().Msg("configuration is valid")
+	return constants.Ok
+}
+
+//TestConnection connects to Host, checks SrcPath exists and probes ApiGatewayHost
+func (app) TestConnection() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := host2host.TestConnection(ctx, config); err != nil {
+		log.Error().Err(err).Msg("connection test failed")
+		return constants.ErrorEstablishedConnection
+	}
+	log.Info().Msg("connection test passed")
+	return constants.Ok
+}
+
+//List prints files the next download would pick up in order they would be processed
+func (app) List() int {
+	if !oneShot("list") {
+		return constants.ErrorConfiguration
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	candidates, err := host2host.List(ctx, config)
+	if err != nil {
+		log.Error().Err(err).Msg("cannot list files")
+		return constants.ErrorEstablishedConnection
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSIZE\tMODIFIED")
+	for _, candidate := range candidates {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", candidate.Rel, candidate.Size, candidate.ModTime.Format(time.RFC3339))
+	}
+	w.Flush()
+	return constants.Ok
+}
+
+//Requeue renames remote file back from .edt and recreates its markers, so the next run picks it up
+func (a app) Requeue() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := host2host.Requeue(ctx, config, a.path); err != nil {
+		log.Error().Err(err).Msgf("cannot requeue %s", a.path)
+		return constants.ErrorEstablishedConnection
+	}
+	return constants.Ok
+}
+
+//Reprocess downloads and processes remote file regardless of its markers
+func (a app) Reprocess() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	downloads, err := host2host.Reprocess(ctx, config, a.path)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot reprocess %s", a.path)
+		return constants.ErrorEstablishedConnection
+	}
+	for _, download := range downloads {
+		if download.Error != nil {
+			log.Error().Err(download.Error).Msgf("error processing file %s", download.SourcePathOriginal)
+			return constants.ErrorProcessing
+		}
+	}
+	return constants.Ok
+}
+
+//Resend sends archived delivery to api-gateway again
+func (a app) Resend() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	download, err := host2host.Resend(ctx, config, a.partner, a.name)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot resend %s of %s", a.name, a.partner)
+		return constants.ErrorEstablishedConnection
+	}
+	if download.Error != nil {
+		log.Error().Err(download.Error).Msgf("error resending file %s", a.name)
+		return constants.ErrorProcessing
+	}
+	return constants.Ok
+}
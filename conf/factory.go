@@ -6,12 +6,15 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
+	"github.com/Deutsche-Boerse/edt-sftp/constants"
 	"github.com/pkg/errors"
 	"github.com/tkanos/gonfig"
 )
 
-const envPath = "EDT_SFTP_CONFIG"
+//EnvPath is environment variable with path to configuration file
+const EnvPath = "EDT_SFTP_CONFIG"
 
 type (
 	ConfigFactory interface {
@@ -22,18 +25,75 @@ type (
 	}
 )
 
+//AcknowledgeFormat describes acknowledge file, it mirrors response.Format
+type AcknowledgeFormat struct {
+	Type       string
+	Name       string
+	Template   string
+	Columns    []string
+	Separator  string
+	Header     bool
+	TimeFormat string
+	Timezone   string
+}
+
 type SftpConfig struct {
-	Type              string
-	Host              string
-	User              string
-	PrivateKeyFile    string
-	SrcPath           string
-	DstPath           string
-	FileMask          string
-	ZeroLenFileSuffix string
-	SShClientConfig   ssh.ClientConfig
-	ApiGatewayHost    string
-	Cron              string
+	Type                string
+	Host                string
+	User                string
+	PrivateKeyFile      string
+	SrcPath             string
+	DstPath             string
+	FileMask            string
+	Include             []string
+	Exclude             []string
+	ExcludeDirs         []string
+	MaxDepth            int
+	Subfolders          []string
+	Order               string
+	MaxFiles            int
+	MaxBytes            int64
+	Fair                bool
+	ZeroLenFileSuffix   string
+	SShClientConfig     ssh.ClientConfig
+	ApiGatewayHost      string
+	Cron                string
+	Overlap             string
+	ShutdownTimeout     string
+	ZipPasswordsPath    string
+	PgpPrivateKeyFile   string
+	PgpPassphraseFile   string
+	PgpKeyringPath      string
+	PgpRequireSignature bool
+	ManifestName        string
+	ManifestRequired    bool
+	Streaming           bool
+	StreamMaxMemory     int64
+	StreamRunMemory     int64
+	StreamMaxUnzipped   int64
+	Readiness           string
+	ReadinessMarker     string
+	ReadinessPolls      int
+	ReadinessMinAge     string
+	ReadinessManifest   string
+	LedgerPath          string
+	DedupWindow         string
+	LedgerRetention     string
+	Acknowledge         AcknowledgeFormat
+	PartnerAcknowledge  map[string]AcknowledgeFormat
+	SendNack            bool
+	Nack                AcknowledgeFormat
+	PartnerNack         map[string]AcknowledgeFormat
+	AckSignature        string
+	AckSigningKeyFile   string
+	OutboxPath          string
+	OutboundPath        string
+	AckTimeout          string
+	ListenAddress       string
+	ArchivePath         string
+	AdminTokenFile      string
+	TraceExporter       string
+	TraceEndpoint       string
 }
 
 // NewFactory is the Factory Method that returns our implementation
@@ -45,15 +105,24 @@ func NewFactory() ConfigFactory {
 // and returns correct config name
 func (configFactoryImpl) Get() (*SftpConfig, error) {
 
-	envPath, exists := os.LookupEnv(envPath)
+	envPath, exists := os.LookupEnv(EnvPath)
 	if !exists {
-		return nil, errors.New(envPath + " variable must be set")
+		return nil, errors.New(EnvPath + " variable must be set")
 	}
 	var config SftpConfig
 	if err := gonfig.GetConf(envPath, &config); err != nil {
 		return &config, errors.Wrapf(err, "can not read configuration from %s", envPath)
 	}
 
+	config.ZipPasswordsPath = resolve(envPath, config.ZipPasswordsPath)
+	config.PgpPrivateKeyFile = resolve(envPath, config.PgpPrivateKeyFile)
+	config.PgpPassphraseFile = resolve(envPath, config.PgpPassphraseFile)
+	config.PgpKeyringPath = resolve(envPath, config.PgpKeyringPath)
+	config.LedgerPath = resolve(envPath, config.LedgerPath)
+	config.AckSigningKeyFile = resolve(envPath, config.AckSigningKeyFile)
+	config.ArchivePath = resolve(envPath, config.ArchivePath)
+	config.AdminTokenFile = resolve(envPath, config.AdminTokenFile)
+
 	pkPath := filepath.Join(path.Dir(envPath), config.PrivateKeyFile)
 	buffer, err := ioutil.ReadFile(pkPath)
 	if err != nil {
@@ -72,3 +141,76 @@ func (configFactoryImpl) Get() (*SftpConfig, error) {
 	}
 	return &config, nil
 }
+
+//ZipPassword reads password of encrypted archives for partner from file ZipPasswordsPath/<partner>.
+//Empty password is returned if ZipPasswordsPath is not configured or partner has no secret file
+func (config *SftpConfig) ZipPassword(partner string) (string, error) {
+	if config.ZipPasswordsPath == "" || partner == "" {
+		return "", nil
+	}
+	secretPath := filepath.Join(config.ZipPasswordsPath, partner)
+	buffer, err := ioutil.ReadFile(secretPath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "can not read zip password from %s", secretPath)
+	}
+	return strings.TrimRight(string(buffer), "\r\n"), nil
+}
+
+//AcknowledgeOf returns acknowledge format of partner, Acknowledge is used for partners not listed in PartnerAcknowledge
+func (config *SftpConfig) AcknowledgeOf(partner string) AcknowledgeFormat {
+	if format, ok := config.PartnerAcknowledge[partner]; ok {
+		return format
+	}
+	return config.Acknowledge
+}
+
+//NackOf returns negative acknowledge format of partner, Nack is used for partners not listed in PartnerNack
+func (config *SftpConfig) NackOf(partner string) AcknowledgeFormat {
+	if format, ok := config.PartnerNack[partner]; ok {
+		return format
+	}
+	return config.Nack
+}
+
+//PgpPassphrase reads passphrase of PgpPrivateKeyFile. Empty passphrase is returned if PgpPassphraseFile is not set
+func (config *SftpConfig) PgpPassphrase() ([]byte, error) {
+	if config.PgpPassphraseFile == "" {
+		return nil, nil
+	}
+	buffer, err := ioutil.ReadFile(config.PgpPassphraseFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can not read pgp passphrase from %s", config.PgpPassphraseFile)
+	}
+	return []byte(strings.TrimRight(string(buffer), "\r\n")), nil
+}
+
+//AdminToken reads token of admin API from AdminTokenFile. Empty token is returned if AdminTokenFile is not set
+func (config *SftpConfig) AdminToken() (string, error) {
+	if config.AdminTokenFile == "" {
+		return "", nil
+	}
+	buffer, err := ioutil.ReadFile(config.AdminTokenFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "can not read admin token from %s", config.AdminTokenFile)
+	}
+	return strings.TrimSpace(string(buffer)), nil
+}
+
+//PgpKeyring returns path to public keyring of partner, i.e. PgpKeyringPath/<partner>.asc
+func (config *SftpConfig) PgpKeyring(partner string) string {
+	if config.PgpKeyringPath == "" {
+		return ""
+	}
+	return filepath.Join(config.PgpKeyringPath, partner+constants.ASC)
+}
+
+//resolve makes relative path relative to folder of configuration file
+func resolve(envPath string, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(path.Dir(envPath), p)
+}
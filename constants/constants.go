@@ -5,6 +5,8 @@ const (
 	Ok                         = 0
 	ErrorEstablishedConnection = 1
 	ErrorConfiguration         = 2
+	ErrorProcessing            = 3
+	ErrorUsage                 = 4
 )
 
 // file extensions
@@ -12,4 +14,10 @@ const (
 	ZIP      = ".zip"
 	EDT      = ".edt"
 	RESPONSE = ".response"
+	NACK     = ".nack"
+	PGP      = ".pgp"
+	GPG      = ".gpg"
+	SIG      = ".sig"
+	ASC      = ".asc"
+	SHA256   = ".sha256"
 )
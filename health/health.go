@@ -0,0 +1,173 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/runner"
+)
+
+// paths of probes
+const (
+	PathHealth = "/healthz"
+	PathReady  = "/readyz"
+	PathStatus = "/status"
+)
+
+//gatewayTTL is how long result of api-gateway probe is reused
+const gatewayTTL = 30 * time.Second
+
+//gatewayTimeout limits api-gateway probe, the probe doesn't depend on request which triggered it
+const gatewayTimeout = 10 * time.Second
+
+// results of checks
+const (
+	ResultOK     = "ok"
+	ResultFailed = "failed"
+)
+
+//Checks are sources of health of the service. Nil functions are skipped
+type Checks struct {
+	//State returns state of runner of downloads
+	State func() runner.State
+	//Next returns time of the next scheduled run
+	Next func() time.Time
+	//Gateway probes api-gateway
+	Gateway func(ctx context.Context) error
+}
+
+//Health tracks health of the service and serves Kubernetes probes. ReportConnection is safe to call on nil receiver
+type Health struct {
+	checks Checks
+	now    func() time.Time
+
+	mu         sync.Mutex
+	connection error
+
+	//probing serializes probes of api-gateway and guards their cached result
+	probing sync.Mutex
+	gateway error
+	probed  time.Time
+}
+
+//Readiness is reply of /readyz
+type Readiness struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+//Status is reply of /status
+type Status struct {
+	RunID    string     `json:"runId,omitempty"`
+	Running  bool       `json:"running"`
+	LastRun  *time.Time `json:"lastRun,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Result   string     `json:"result,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	NextRun  *time.Time `json:"nextRun,omitempty"`
+}
+
+//New creates health of service
+func New(checks Checks) *Health {
+	return &Health{checks: checks, now: time.Now}
+}
+
+//ReportConnection records outcome of the last connection to remote
+func (h *Health) ReportConnection(err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.connection = err
+}
+
+//Register mounts /healthz, /readyz and /status
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.HandleFunc(PathHealth, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(ResultOK + "\n"))
+	})
+	mux.HandleFunc(PathReady, func(w http.ResponseWriter, r *http.Request) {
+		readiness := h.Readiness()
+		status := http.StatusOK
+		if !readiness.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, readiness)
+	})
+	mux.HandleFunc(PathStatus, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, h.Status())
+	})
+}
+
+//Readiness checks configuration is loaded, the last connection to remote succeeded and api-gateway is reachable.
+//Connection is considered ok before the first run
+func (h *Health) Readiness() Readiness {
+	readiness := Readiness{Ready: true, Checks: map[string]string{"config": ResultOK}}
+	check := func(name string, err error) {
+		readiness.Checks[name] = ResultOK
+		if err != nil {
+			readiness.Ready = false
+			readiness.Checks[name] = err.Error()
+		}
+	}
+	h.mu.Lock()
+	connection := h.connection
+	h.mu.Unlock()
+	check("connection", connection)
+	if h.checks.Gateway != nil {
+		check("gateway", h.probeGateway())
+	}
+	return readiness
+}
+
+//Status returns time and result of the last run and time of the next one
+func (h *Health) Status() Status {
+	status := Status{}
+	if h.checks.State != nil {
+		state := h.checks.State()
+		status.RunID = state.RunID
+		status.Running = state.Running
+		if !state.Started.IsZero() {
+			status.LastRun = &state.Started
+		}
+		if !state.Finished.IsZero() {
+			status.Finished = &state.Finished
+			status.Result = ResultOK
+			if state.LastError != nil {
+				status.Result = ResultFailed
+				status.Error = state.LastError.Error()
+			}
+		}
+	}
+	if h.checks.Next != nil {
+		if next := h.checks.Next(); !next.IsZero() {
+			status.NextRun = &next
+		}
+	}
+	return status
+}
+
+//probeGateway returns result of api-gateway probe; the result is cached for gatewayTTL. Probe doesn't hold mu,
+//so reporting connection isn't blocked by slow api-gateway
+func (h *Health) probeGateway() error {
+	h.probing.Lock()
+	defer h.probing.Unlock()
+	if !h.probed.IsZero() && h.now().Sub(h.probed) < gatewayTTL {
+		return h.gateway
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gatewayTimeout)
+	defer cancel()
+	h.gateway = h.checks.Gateway(ctx)
+	h.probed = h.now()
+	return h.gateway
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
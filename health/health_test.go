@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/runner"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func get(mux *http.ServeMux, path string, v interface{}) int {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if v != nil {
+		json.Unmarshal(w.Body.Bytes(), v)
+	}
+	return w.Code
+}
+
+func TestProbes(t *testing.T) {
+	//arrange
+	probes := 0
+	h := New(Checks{Gateway: func(ctx context.Context) error {
+		probes++
+		return nil
+	}})
+	mux := http.NewServeMux()
+	h.Register(mux)
+
+	//act
+	healthCode := get(mux, PathHealth, nil)
+	var ready Readiness
+	readyCode := get(mux, PathReady, &ready)
+	h.ReportConnection(errors.New("connection refused"))
+	var notReady Readiness
+	notReadyCode := get(mux, PathReady, &notReady)
+
+	//assert
+	assert.Equal(t, http.StatusOK, healthCode)
+	assert.Equal(t, http.StatusOK, readyCode)
+	assert.True(t, ready.Ready)
+	assert.Equal(t, map[string]string{"config": ResultOK, "connection": ResultOK, "gateway": ResultOK}, ready.Checks)
+	assert.Equal(t, http.StatusServiceUnavailable, notReadyCode)
+	assert.False(t, notReady.Ready)
+	assert.Equal(t, "connection refused", notReady.Checks["connection"])
+	assert.Equal(t, 1, probes, "gateway probe is cached")
+}
+
+func TestGatewayProbeExpires(t *testing.T) {
+	//arrange
+	now := time.Now()
+	h := New(Checks{Gateway: func(ctx context.Context) error { return errors.New("unreachable") }})
+	h.now = func() time.Time { return now }
+	first := h.Readiness()
+	h.checks.Gateway = func(ctx context.Context) error { return nil }
+
+	//act
+	cached := h.Readiness()
+	now = now.Add(gatewayTTL)
+	expired := h.Readiness()
+
+	//assert
+	assert.False(t, first.Ready)
+	assert.False(t, cached.Ready)
+	assert.True(t, expired.Ready)
+}
+
+func TestGatewayProbeDoesNotBlockConnection(t *testing.T) {
+	//arrange
+	release := make(chan struct{})
+	var deadline bool
+	h := New(Checks{Gateway: func(ctx context.Context) error {
+		_, deadline = ctx.Deadline()
+		<-release
+		return ctx.Err()
+	}})
+	mux := http.NewServeMux()
+	h.Register(mux)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan Readiness)
+	go func() {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, PathReady, nil).WithContext(cancelled))
+		var readiness Readiness
+		json.Unmarshal(w.Body.Bytes(), &readiness)
+		done <- readiness
+	}()
+
+	//act
+	reported := make(chan struct{})
+	go func() {
+		h.ReportConnection(nil)
+		close(reported)
+	}()
+	var blocked bool
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		blocked = true
+	}
+	close(release)
+	readiness := <-done
+
+	//assert
+	assert.False(t, blocked, "connection is reported while gateway is probed")
+	assert.True(t, deadline)
+	assert.True(t, readiness.Ready, "cancelled request doesn't fail the probe")
+}
+
+func TestStatus(t *testing.T) {
+	//arrange
+	started := time.Date(2018, 8, 8, 10, 0, 0, 0, time.UTC)
+	next := started.Add(10 * time.Minute)
+	h := New(Checks{
+		State: func() runner.State {
+			return runner.State{RunID: "20180808T100000-1", Started: started, Finished: started.Add(time.Minute),
+				LastError: errors.New("failed downloading")}
+		},
+		Next: func() time.Time { return next },
+	})
+	mux := http.NewServeMux()
+	h.Register(mux)
+
+	//act
+	var status Status
+	code := get(mux, PathStatus, &status)
+
+	//assert
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "20180808T100000-1", status.RunID)
+	assert.Equal(t, ResultFailed, status.Result)
+	assert.Equal(t, "failed downloading", status.Error)
+	assert.True(t, started.Equal(*status.LastRun))
+	assert.True(t, next.Equal(*status.NextRun))
+	assert.False(t, status.Running)
+}
+
+func TestNilHealth(t *testing.T) {
+	//arrange
+	var h *Health
+
+	//assert
+	assert.NotPanics(t, func() { h.ReportConnection(errors.New("connection refused")) })
+}
@@ -1,50 +1,290 @@
 package host2host
 
 import (
+	"context"
+	"time"
+
 	"github.com/Deutsche-Boerse/edt-sftp/client"
 	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
 	"github.com/Deutsche-Boerse/edt-sftp/conf"
+	"github.com/Deutsche-Boerse/edt-sftp/ledger"
+	"github.com/Deutsche-Boerse/edt-sftp/pipeline"
+	"github.com/Deutsche-Boerse/edt-sftp/readiness"
+	"github.com/Deutsche-Boerse/edt-sftp/selector"
+	"github.com/Deutsche-Boerse/edt-sftp/tracing"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 )
 
 // error messages
 const (
 	errConfig    string = "failed to get config "
-	errDownload  string = "failed downloading "
-	errUnzip     string = "failed unzipping "
-	errResponse  string = "failed sending response "
-	errClean     string = "failed cleaning "
 	errNilConfig string = "config is nil "
+	errLedger    string = "failed opening ledger "
+	errPush      string = "failed pushing "
+	errResend    string = "failed resending "
 )
 
-//Download files from remote and POST them to endpoint specified by config
-func Download(config *conf.SftpConfig) ([]*structs.DownloadInfo, error) {
-	var downloads []*structs.DownloadInfo
+//Push uploads files waiting in outbox to remote outbound folders of partners. Push is traced in span with child span per file
+func Push(ctx context.Context, config *conf.SftpConfig) (uploads []*structs.UploadInfo, err error) {
+	ctx, span := tracing.Start(ctx, "push")
+	defer func() { tracing.End(span, err) }()
+	if config == nil {
+		return nil, errors.New(errNilConfig)
+	}
+	if config.OutboxPath == "" {
+		return nil, nil
+	}
+	l, err := openLedger(config)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config, Ledger: l}).Get()
+	if err != nil {
+		return nil, errors.New(errConfig + err.Error())
+	}
+	uploads, err = c.Push(ctx)
+	if err != nil {
+		return uploads, errors.New(errPush + err.Error())
+	}
+	return uploads, nil
+}
+
+//Download files from remote and POST them to endpoint specified by config. Once ctx is done processing stops,
+//files already sent are responded and cleaned and the others are rolled back to their original names,
+//so they are picked up by the next run.
+//Download is traced in span with child span per stage. Session carries state between runs, nil starts from scratch
+func Download(ctx context.Context, config *conf.SftpConfig, session *Session) (downloads []*structs.DownloadInfo, err error) {
+	ctx, span := tracing.Start(ctx, "download")
+	defer func() { tracing.End(span, err) }()
 	if config == nil {
 		return downloads, errors.New(errNilConfig)
 	}
+	l, err := openLedger(config)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	options := client.ClientOptions{SftpConfig: config, Ledger: l}
+	if session != nil {
+		options.Readiness, options.Planner = session.Readiness, session.Planner
+	}
+	c, err := client.NewFactory(options).Get()
+	if err != nil {
+		return nil, errors.New(errConfig + err.Error())
+	}
+	downloads, err = c.Download(ctx)
+	//nothing has been downloaded
+	if err == nil && downloads == nil {
+		return nil, nil
+	}
+	return pipeline.Complete(ctx, c, downloads, err)
+}
+
+//Reprocess downloads and processes single remote file regardless of file selection and readiness markers
+func Reprocess(ctx context.Context, config *conf.SftpConfig, remoteFile string) ([]*structs.DownloadInfo, error) {
+	if config == nil {
+		return nil, errors.New(errNilConfig)
+	}
+	l, err := openLedger(config)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config, Ledger: l}).Get()
+	if err != nil {
+		return nil, errors.New(errConfig + err.Error())
+	}
+	downloads, err := c.Fetch(ctx, remoteFile)
+	return pipeline.Complete(ctx, c, downloads, err)
+}
+
+//Requeue renames remote .edt file back to its original name and recreates its markers
+func Requeue(ctx context.Context, config *conf.SftpConfig, remoteFile string) error {
+	if config == nil {
+		return errors.New(errNilConfig)
+	}
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config}).Get()
+	if err != nil {
+		return errors.New(errConfig + err.Error())
+	}
+	return c.Requeue(ctx, remoteFile)
+}
+
+//Resend sends delivery archived in ArchivePath/<partner>/<name> to api-gateway again
+func Resend(ctx context.Context, config *conf.SftpConfig, partner string, name string) (*structs.DownloadInfo, error) {
+	if config == nil {
+		return nil, errors.New(errNilConfig)
+	}
 	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config}).Get()
 	if err != nil {
 		return nil, errors.New(errConfig + err.Error())
 	}
-	if downloads, err = c.Download(); err != nil {
-		return downloads, errors.New(errDownload + err.Error())
+	download, err := c.Resend(ctx, partner, name)
+	if err != nil {
+		return download, errors.New(errResend + err.Error())
 	}
-	//nothing has been downloaded
-	if downloads == nil {
+	return download, nil
+}
+
+//Check validates configuration without connecting to remote
+func Check(config *conf.SftpConfig) error {
+	if config == nil {
+		return errors.New(errNilConfig)
+	}
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config}).Get()
+	if err != nil {
+		return errors.New(errConfig + err.Error())
+	}
+	return c.Check()
+}
+
+//TestConnection connects to remote, checks SrcPath and probes api-gateway
+func TestConnection(ctx context.Context, config *conf.SftpConfig) error {
+	if config == nil {
+		return errors.New(errNilConfig)
+	}
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config}).Get()
+	if err != nil {
+		return errors.New(errConfig + err.Error())
+	}
+	return c.TestConnection(ctx)
+}
+
+//ProbeGateway checks api-gateway is reachable
+func ProbeGateway(ctx context.Context, config *conf.SftpConfig) error {
+	if config == nil {
+		return errors.New(errNilConfig)
+	}
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config}).Get()
+	if err != nil {
+		return errors.New(errConfig + err.Error())
+	}
+	return c.ProbeGateway(ctx)
+}
+
+//Session is state the service carries between runs: stable readiness remembers sizes of files
+//and fair planner remembers which partners it has served
+type Session struct {
+	Readiness readiness.Strategy
+	Planner   *selector.Planner
+}
+
+//NewSession creates session of config, the service passes it to every Download
+func NewSession(config *conf.SftpConfig) (*Session, error) {
+	if config == nil {
+		return nil, errors.New(errNilConfig)
+	}
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config}).Get()
+	if err != nil {
+		return nil, errors.New(errConfig + err.Error())
+	}
+	session := &Session{}
+	if session.Readiness, err = c.Strategy(); err != nil {
+		return nil, err
+	}
+	if session.Planner, err = c.Planner(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+//PurgeWorkDirs removes stale working directories left in DstPath by previous process
+func PurgeWorkDirs(config *conf.SftpConfig) error {
+	if config == nil {
+		return errors.New(errNilConfig)
+	}
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config}).Get()
+	if err != nil {
+		return errors.New(errConfig + err.Error())
+	}
+	c.PurgeWorkDirs()
+	return nil
+}
+
+//List returns files the next download would pick up
+func List(ctx context.Context, config *conf.SftpConfig) ([]selector.Candidate, error) {
+	if config == nil {
+		return nil, errors.New(errNilConfig)
+	}
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config}).Get()
+	if err != nil {
+		return nil, errors.New(errConfig + err.Error())
+	}
+	return c.List(ctx)
+}
+
+//DryRun returns what download and push would do without modifying remote or calling api-gateway
+func DryRun(ctx context.Context, config *conf.SftpConfig) ([]*structs.PlanInfo, error) {
+	if config == nil {
+		return nil, errors.New(errNilConfig)
+	}
+	c, err := client.NewFactory(client.ClientOptions{SftpConfig: config}).Get()
+	if err != nil {
+		return nil, errors.New(errConfig + err.Error())
+	}
+	return c.DryRun(ctx)
+}
+
+//Duplicates returns deliveries recognized as duplicates after since. Requires LedgerPath
+func Duplicates(config *conf.SftpConfig, since time.Time) ([]ledger.Entry, error) {
+	if config == nil {
+		return nil, errors.New(errNilConfig)
+	}
+	if config.LedgerPath == "" {
+		return nil, errors.New(errLedger + "LedgerPath is not configured")
+	}
+	l, err := openLedger(config)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	return l.Duplicates(since)
+}
+
+//defaultLedgerRetention is how long ledger keeps entries when LedgerRetention is not configured
+const defaultLedgerRetention = 90 * 24 * time.Hour
+
+//openLedger opens ledger if configured, nil ledger otherwise. Entries older than retention are pruned
+func openLedger(config *conf.SftpConfig) (*ledger.Ledger, error) {
+	if config.LedgerPath == "" {
 		return nil, nil
 	}
-	if err = c.Unzip(downloads); err != nil {
-		return downloads, errors.New(errUnzip + err.Error())
+	retention, err := ledgerRetention(config)
+	if err != nil {
+		return nil, errors.New(errLedger + err.Error())
 	}
-	if err = c.SendToEdt(downloads); err != nil {
-		return downloads, errors.New(errResponse + err.Error())
+	l, err := ledger.Open(config.LedgerPath)
+	if err != nil {
+		return nil, errors.New(errLedger + err.Error())
 	}
-	if err = c.SendResponses(downloads); err != nil {
-		return downloads, errors.New(errResponse + err.Error())
+	removed, err := l.Prune(time.Now().Add(-retention))
+	if err != nil {
+		log.Error().Err(err).Msgf("failed pruning ledger %s", config.LedgerPath)
+	} else if removed > 0 {
+		log.Info().Msgf("pruned %d ledger entries older than %s", removed, retention)
+	}
+	return l, nil
+}
+
+//ledgerRetention returns LedgerRetention, never shorter than DedupWindow which must still find sent content
+func ledgerRetention(config *conf.SftpConfig) (time.Duration, error) {
+	retention := defaultLedgerRetention
+	if config.LedgerRetention != "" {
+		var err error
+		if retention, err = time.ParseDuration(config.LedgerRetention); err != nil {
+			return 0, errors.Wrap(err, "invalid LedgerRetention "+config.LedgerRetention)
+		}
 	}
-	if err = c.Clean(downloads); err != nil {
-		return downloads, errors.New(errClean + err.Error())
+	if config.DedupWindow != "" {
+		window, err := time.ParseDuration(config.DedupWindow)
+		if err != nil {
+			return 0, errors.Wrap(err, "invalid DedupWindow "+config.DedupWindow)
+		}
+		if window > retention {
+			retention = window
+		}
 	}
-	return downloads, nil
+	return retention, nil
 }
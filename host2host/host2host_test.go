@@ -1,6 +1,7 @@
 package host2host
 
 import (
+	"context"
 	"fmt"
 	"github.com/Deutsche-Boerse/edt-sftp/conf"
 	"github.com/Deutsche-Boerse/edt-sftp/constants"
@@ -50,7 +51,7 @@ func TestSuccessfullyDownloadedOneFile(t *testing.T) {
 	config, err := testInit(testData.OutPathSftpBrcls, tested, tested+"_0")
 
 	//act
-	downloaded, err := Download(config)
+	downloaded, err := Download(context.Background(), config, nil)
 
 	//assert
 	var exists bool
@@ -80,7 +81,7 @@ func TestSuccessfullyMultipleFiles(t *testing.T) {
 	assert.NoError(t, err, ErrExpectedConfiguration)
 
 	//act
-	downloaded, err := Download(config)
+	downloaded, err := Download(context.Background(), config, nil)
 
 	//assert
 	assert.NoError(t, err)
@@ -116,7 +117,7 @@ func TestDownloadCorruptedFile(t *testing.T) {
 	assert.NoError(t, err, ErrExpectedConfiguration)
 
 	//act
-	downloaded, err := Download(config)
+	downloaded, err := Download(context.Background(), config, nil)
 
 	//assert
 	assert.NoError(t, err)
@@ -146,7 +147,7 @@ func TestDownloadEmptyFile(t *testing.T) {
 	assert.NoError(t, err, ErrExpectedConfiguration)
 
 	//act
-	downloaded, err := Download(config)
+	downloaded, err := Download(context.Background(), config, nil)
 
 	//assert
 	assert.NoError(t, err)
@@ -175,7 +176,7 @@ func TestDownloadWithoutZeroLenFile(t *testing.T) {
 	assert.NoError(t, err, ErrExpectedConfiguration)
 
 	//act
-	downloaded, err := Download(config)
+	downloaded, err := Download(context.Background(), config, nil)
 
 	//assert
 	assert.NoError(t, err)
@@ -207,7 +208,7 @@ func TestDownloadWithZeroLenFileOnly(t *testing.T) {
 	assert.NoError(t, err, ErrExpectedConfiguration)
 
 	//act
-	downloaded, err := Download(config)
+	downloaded, err := Download(context.Background(), config, nil)
 
 	//assert
 	assert.NoError(t, err)
@@ -238,7 +239,7 @@ func TestAckAlreadyExists(t *testing.T) {
 	config, _ := testInit(testData.OutPathSftpBrcls, tested, tested+"_0", tested+constants.RESPONSE)
 
 	//act
-	downloaded, err := Download(config)
+	downloaded, err := Download(context.Background(), config, nil)
 
 	//assert
 	var exists bool
@@ -270,7 +271,7 @@ func TestDownloadEmptyFolders(t *testing.T) {
 	//arrange
 	//act
 	config, _ := testInit(testData.OutPathSftpEmpty)
-	downloaded, err := Download(config)
+	downloaded, err := Download(context.Background(), config, nil)
 
 	//assert
 	assert.NoError(t, err)
@@ -280,7 +281,7 @@ func TestDownloadEmptyFolders(t *testing.T) {
 func TestNilConfig(t *testing.T) {
 	//arrange
 	//act
-	downloaded, err := Download(nil)
+	downloaded, err := Download(context.Background(), nil, nil)
 
 	//assert
 	assert.Error(t, err)
@@ -0,0 +1,323 @@
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// stages recorded in ledger
+const (
+	StageDownloaded = "downloaded"
+	StageSent       = "sent"
+	StageResponded  = "responded"
+	StageCleaned    = "cleaned"
+	//StageDuplicate is recorded instead of StageSent for content which has been already sent
+	StageDuplicate = "duplicate"
+	//StageRejected records negative acknowledge sent to partner
+	StageRejected = "rejected"
+	//StageUploaded and StageAcknowledged record outbound file pushed to partner and its acknowledge
+	StageUploaded     = "uploaded"
+	StageAcknowledged = "acknowledged"
+)
+
+// outcome statuses
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// error messages
+const (
+	ErrOpen = "cannot open ledger "
+)
+
+var (
+	entriesBucket = []byte("entries")
+	//hashesBucket maps content hash to key of the first delivery sent with that content
+	hashesBucket = []byte("hashes")
+)
+
+//openTimeout is how long Open waits for lock held by another process
+const openTimeout = 5 * time.Second
+
+//Key identifies delivery. The same remote file with the same size, modification time and content
+//has the same key, even when it reappears after it was renamed back from .edt
+type Key struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Hash    string
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%s|%d|%d|%s", k.Path, k.Size, k.ModTime.UnixNano(), k.Hash)
+}
+
+//Outcome of single stage
+type Outcome struct {
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+//Entry is ledger record of delivery
+type Entry struct {
+	Key     Key                `json:"key"`
+	Partner string             `json:"partner,omitempty"`
+	Stages  map[string]Outcome `json:"stages"`
+	Updated time.Time          `json:"updated"`
+	//Receipt is identifier assigned by gateway to sent delivery
+	Receipt string `json:"receipt,omitempty"`
+	//DuplicateOf is key of delivery with the same content sent before
+	DuplicateOf *Key `json:"duplicateOf,omitempty"`
+}
+
+//Done returns true if stage finished successfully
+func (e *Entry) Done(stage string) bool {
+	if e == nil {
+		return false
+	}
+	outcome, ok := e.Stages[stage]
+	return ok && outcome.Status == StatusOK
+}
+
+//Ledger is embedded BoltDB database recording outcome of every processing stage of every delivery,
+//so retries resume where they stopped and nothing is sent twice. Methods of nil Ledger do nothing
+type Ledger struct {
+	db *bolt.DB
+}
+
+//Open opens or creates ledger file
+func Open(file string) (*Ledger, error) {
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return nil, errors.Wrap(err, ErrOpen+file)
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, errors.Wrap(err, ErrOpen+file)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{entriesBucket, hashesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, ErrOpen+file)
+	}
+	return &Ledger{db: db}, nil
+}
+
+//Close closes ledger file
+func (l *Ledger) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.db.Close()
+}
+
+//Get returns entry of delivery or nil if delivery is not recorded
+func (l *Ledger) Get(key Key) (*Entry, error) {
+	if l == nil {
+		return nil, nil
+	}
+	var entry *Entry
+	err := l.db.View(func(tx *bolt.Tx) error {
+		var err error
+		entry, err = get(tx, key)
+		return err
+	})
+	return entry, err
+}
+
+//Record stores outcome of stage of delivery; nil err means stage finished successfully.
+//Successfully sent content is indexed by hash for duplicate detection
+func (l *Ledger) Record(key Key, partner string, stage string, stageErr error) error {
+	if l == nil {
+		return nil
+	}
+	return l.update(key, partner, stage, stageErr, nil)
+}
+
+//RecordDuplicate records that content of delivery has been already sent as original
+func (l *Ledger) RecordDuplicate(key Key, partner string, original Key) error {
+	if l == nil {
+		return nil
+	}
+	return l.update(key, partner, StageDuplicate, nil, &original)
+}
+
+//RecordReceipt stores receipt id assigned by gateway to delivery
+func (l *Ledger) RecordReceipt(key Key, receipt string) error {
+	if l == nil {
+		return nil
+	}
+	return l.db.Update(func(tx *bolt.Tx) error {
+		entry, err := get(tx, key)
+		if err != nil || entry == nil {
+			return err
+		}
+		entry.Receipt = receipt
+		return put(tx, entry)
+	})
+}
+
+//Original returns entry of the latest delivery with the same content as key which was sent after since.
+//Returns nil if content hasn't been sent or it was sent by the same delivery
+func (l *Ledger) Original(key Key, since time.Time) (*Entry, error) {
+	if l == nil || key.Hash == "" {
+		return nil, nil
+	}
+	var entry *Entry
+	err := l.db.View(func(tx *bolt.Tx) error {
+		original := tx.Bucket(hashesBucket).Get([]byte(key.Hash))
+		if original == nil || string(original) == key.String() {
+			return nil
+		}
+		value := tx.Bucket(entriesBucket).Get(original)
+		if value == nil {
+			return nil
+		}
+		e := &Entry{}
+		if err := json.Unmarshal(value, e); err != nil {
+			return errors.Wrapf(err, "corrupted ledger entry %s", original)
+		}
+		if e.Done(StageSent) && !e.Stages[StageSent].Time.Before(since) {
+			entry = e
+		}
+		return nil
+	})
+	return entry, err
+}
+
+//Duplicates returns deliveries recognized as duplicates after since
+func (l *Ledger) Duplicates(since time.Time) ([]Entry, error) {
+	var duplicates []Entry
+	if l == nil {
+		return duplicates, nil
+	}
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).ForEach(func(k, value []byte) error {
+			e := Entry{}
+			if err := json.Unmarshal(value, &e); err != nil {
+				return errors.Wrapf(err, "corrupted ledger entry %s", k)
+			}
+			if e.DuplicateOf != nil && !e.Stages[StageDuplicate].Time.Before(since) {
+				duplicates = append(duplicates, e)
+			}
+			return nil
+		})
+	})
+	return duplicates, err
+}
+
+//Prune removes entries last updated before cutoff together with hashes of content they sent,
+//so ledger doesn't grow forever. Returns number of removed entries
+func (l *Ledger) Prune(before time.Time) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	removed := 0
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		var stale [][]byte
+		err := entries.ForEach(func(k, value []byte) error {
+			e := Entry{}
+			if err := json.Unmarshal(value, &e); err != nil {
+				return errors.Wrapf(err, "corrupted ledger entry %s", k)
+			}
+			if e.Updated.Before(before) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err = entries.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(stale)
+		//bucket must not be modified while iterating it
+		hashes := tx.Bucket(hashesBucket)
+		var orphans [][]byte
+		err = hashes.ForEach(func(hash, original []byte) error {
+			if entries.Get(original) == nil {
+				orphans = append(orphans, append([]byte(nil), hash...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, hash := range orphans {
+			if err = hashes.Delete(hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (l *Ledger) update(key Key, partner string, stage string, stageErr error, duplicateOf *Key) error {
+	now := time.Now().UTC()
+	outcome := Outcome{Status: StatusOK, Time: now}
+	if stageErr != nil {
+		outcome = Outcome{Status: StatusFailed, Error: stageErr.Error(), Time: now}
+	}
+	return l.db.Update(func(tx *bolt.Tx) error {
+		entry, err := get(tx, key)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			entry = &Entry{Key: key, Stages: map[string]Outcome{}}
+		}
+		if partner != "" {
+			entry.Partner = partner
+		}
+		if duplicateOf != nil {
+			entry.DuplicateOf = duplicateOf
+		}
+		entry.Stages[stage] = outcome
+		entry.Updated = now
+		if stage == StageSent && stageErr == nil && key.Hash != "" {
+			if err = tx.Bucket(hashesBucket).Put([]byte(key.Hash), []byte(key.String())); err != nil {
+				return err
+			}
+		}
+		return put(tx, entry)
+	})
+}
+
+func put(tx *bolt.Tx, entry *Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(entriesBucket).Put([]byte(entry.Key.String()), value)
+}
+
+func get(tx *bolt.Tx, key Key) (*Entry, error) {
+	value := tx.Bucket(entriesBucket).Get([]byte(key.String()))
+	if value == nil {
+		return nil, nil
+	}
+	entry := &Entry{}
+	if err := json.Unmarshal(value, entry); err != nil {
+		return nil, errors.Wrapf(err, "corrupted ledger entry %s", key)
+	}
+	return entry, nil
+}
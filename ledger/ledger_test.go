@@ -0,0 +1,204 @@
+package ledger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/utils"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var testData = struct {
+	OutPath string
+}{
+	filepath.Join("testdata", "out"),
+}
+
+var key = Key{
+	Path:    "/home/ec2-user/COBA/KV1212_T_EDT_Bonds180808.zip",
+	Size:    1024,
+	ModTime: time.Date(2018, 8, 8, 14, 53, 32, 0, time.UTC),
+	Hash:    "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+}
+
+func TestMain(m *testing.M) {
+	//if anything failed before and files are still present
+	utils.RemoveAllExcept(filepath.Join(testData.OutPath, ".gitkeep"))
+	m.Run()
+	//cleaning
+	utils.RemoveAllExcept(filepath.Join(testData.OutPath, ".gitkeep"))
+}
+
+func TestRecordSurvivesReopen(t *testing.T) {
+	//arrange
+	file := filepath.Join(testData.OutPath, "reopen.db")
+	l, err := Open(file)
+	assert.NoError(t, err)
+
+	//act
+	assert.NoError(t, l.Record(key, "COBA", StageDownloaded, nil))
+	assert.NoError(t, l.Record(key, "", StageSent, nil))
+	assert.NoError(t, l.Record(key, "", StageResponded, errors.New("connection lost")))
+	assert.NoError(t, l.Close())
+	l, err = Open(file)
+	assert.NoError(t, err)
+	defer l.Close()
+	entry, err := l.Get(key)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, "COBA", entry.Partner)
+	assert.True(t, entry.Done(StageDownloaded))
+	assert.True(t, entry.Done(StageSent))
+	assert.False(t, entry.Done(StageResponded))
+	assert.Equal(t, "connection lost", entry.Stages[StageResponded].Error)
+	assert.False(t, entry.Done(StageCleaned))
+}
+
+func TestDifferentContentIsDifferentEntry(t *testing.T) {
+	//arrange
+	l, err := Open(filepath.Join(testData.OutPath, "content.db"))
+	assert.NoError(t, err)
+	defer l.Close()
+	assert.NoError(t, l.Record(key, "COBA", StageSent, nil))
+	changed := key
+	changed.Hash = "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+
+	//act
+	entry, err := l.Get(changed)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+	assert.False(t, entry.Done(StageSent))
+}
+
+func TestNilLedgerDoesNothing(t *testing.T) {
+	//arrange
+	var l *Ledger
+
+	//act
+	err := l.Record(key, "COBA", StageSent, nil)
+	entry, getErr := l.Get(key)
+
+	//assert
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Nil(t, entry)
+	assert.NoError(t, l.Close())
+}
+
+func TestOriginalWithinWindow(t *testing.T) {
+	//arrange
+	l, err := Open(filepath.Join(testData.OutPath, "dedup.db"))
+	assert.NoError(t, err)
+	defer l.Close()
+	resent := key
+	resent.Path = "/home/ec2-user/BRCLS/KV1212_T_EDT_Bonds180809.zip"
+	assert.NoError(t, l.Record(key, "COBA", StageSent, nil))
+
+	//act
+	original, err := l.Original(resent, time.Now().Add(-time.Hour))
+	self, selfErr := l.Original(key, time.Now().Add(-time.Hour))
+	expired, expiredErr := l.Original(resent, time.Now().Add(time.Hour))
+
+	//assert
+	assert.NoError(t, err)
+	assert.NoError(t, selfErr)
+	assert.NoError(t, expiredErr)
+	assert.Equal(t, key.Path, original.Key.Path)
+	assert.Nil(t, self, "delivery is not duplicate of itself")
+	assert.Nil(t, expired, "original sent before window is ignored")
+}
+
+func TestFailedSendIsNotOriginal(t *testing.T) {
+	//arrange
+	l, err := Open(filepath.Join(testData.OutPath, "failed.db"))
+	assert.NoError(t, err)
+	defer l.Close()
+	resent := key
+	resent.Path = "/home/ec2-user/COBA/KV1212_T_EDT_Bonds180809.zip"
+	assert.NoError(t, l.Record(key, "COBA", StageSent, errors.New("503")))
+
+	//act
+	original, err := l.Original(resent, time.Time{})
+
+	//assert
+	assert.NoError(t, err)
+	assert.Nil(t, original)
+}
+
+func TestDuplicatesReport(t *testing.T) {
+	//arrange
+	l, err := Open(filepath.Join(testData.OutPath, "report.db"))
+	assert.NoError(t, err)
+	defer l.Close()
+	resent := key
+	resent.Path = "/home/ec2-user/BRCLS/KV1212_T_EDT_Bonds180809.zip"
+	assert.NoError(t, l.Record(key, "COBA", StageSent, nil))
+	assert.NoError(t, l.RecordDuplicate(resent, "BRCLS", key))
+
+	//act
+	duplicates, err := l.Duplicates(time.Now().Add(-time.Hour))
+
+	//assert
+	assert.NoError(t, err)
+	assert.Len(t, duplicates, 1)
+	assert.Equal(t, "BRCLS", duplicates[0].Partner)
+	assert.Equal(t, key.Path, duplicates[0].DuplicateOf.Path)
+	assert.True(t, duplicates[0].Done(StageDuplicate))
+	assert.False(t, duplicates[0].Done(StageSent))
+}
+
+func TestRecordReceipt(t *testing.T) {
+	//arrange
+	l, err := Open(filepath.Join(testData.OutPath, "receipt.db"))
+	assert.NoError(t, err)
+	defer l.Close()
+	assert.NoError(t, l.Record(key, "COBA", StageSent, nil))
+
+	//act
+	err = l.RecordReceipt(key, "R-2018-0001")
+	entry, getErr := l.Get(key)
+
+	//assert
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Equal(t, "R-2018-0001", entry.Receipt)
+	assert.True(t, entry.Done(StageSent))
+}
+
+func TestPruneRemovesOldEntriesAndTheirHashes(t *testing.T) {
+	//arrange
+	l, err := Open(filepath.Join(testData.OutPath, "prune.db"))
+	assert.NoError(t, err)
+	defer l.Close()
+	assert.NoError(t, l.Record(key, "COBA", StageSent, nil))
+	time.Sleep(time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	recent := key
+	recent.Path = "/home/ec2-user/BRCLS/KV1212_T_EDT_Bonds180809.zip"
+	recent.Hash = "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+	assert.NoError(t, l.Record(recent, "BRCLS", StageSent, nil))
+	resent := key
+	resent.Path = "/home/ec2-user/DBAG/KV1212_T_EDT_Bonds180808.zip"
+
+	//act
+	removed, err := l.Prune(cutoff)
+	old, oldErr := l.Get(key)
+	kept, keptErr := l.Get(recent)
+	original, originalErr := l.Original(resent, time.Time{})
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoError(t, oldErr)
+	assert.Nil(t, old)
+	assert.NoError(t, keptErr)
+	assert.True(t, kept.Done(StageSent))
+	assert.NoError(t, originalErr)
+	assert.Nil(t, original)
+}
@@ -1,71 +1,167 @@
 package main
 
 import (
+	"context"
+	"net/http"
 	"os"
 	"os/signal"
-	"io/ioutil"
+	"syscall"
+	"time"
 
+	"github.com/Deutsche-Boerse/edt-sftp/admin"
 	"github.com/Deutsche-Boerse/edt-sftp/conf"
 	"github.com/Deutsche-Boerse/edt-sftp/constants"
+	"github.com/Deutsche-Boerse/edt-sftp/health"
 	"github.com/Deutsche-Boerse/edt-sftp/host2host"
+	"github.com/Deutsche-Boerse/edt-sftp/metrics"
+	"github.com/Deutsche-Boerse/edt-sftp/outbox"
+	"github.com/Deutsche-Boerse/edt-sftp/runner"
+	"github.com/Deutsche-Boerse/edt-sftp/tracing"
 
 	"github.com/pkg/errors"
 	"github.com/robfig/cron"
 	"github.com/rs/zerolog/log"
 )
 
-var isDownloading = false
 var config *conf.SftpConfig
 
-type app struct{}
+//probes tracks health of the service; nil outside of run command
+var probes *health.Health
+
+//session keeps state between runs of the service; nil outside of run command
+var session *host2host.Session
+
+//defaultShutdownTimeout is used when ShutdownTimeout is not configured
+const defaultShutdownTimeout = time.Minute
+
+type app struct {
+	//dryRun makes once print plan instead of processing files
+	dryRun bool
+	//path is remote file of requeue and reprocess, partner and name identify archived delivery of resend
+	path    string
+	partner string
+	name    string
+}
 
 //Run main function in CRON job
 func (app) Run() int {
-	con, err := conf.NewFactory().Get()
+	jobs, shutdownTimeout, err := schedule(config)
 	if err != nil {
-		log.Error().Err(err).Msg("unable to read configuration")
+		log.Error().Err(err).Msg("invalid configuration")
+		return constants.ErrorConfiguration
+	}
+	if session, err = host2host.NewSession(config); err != nil {
+		log.Error().Err(err).Msg("invalid configuration")
 		return constants.ErrorConfiguration
 	}
-	config = con
-	log.Info().Msgf("sftp service started... %s", config.Cron)
 	c := cron.New()
-	c.AddFunc(config.Cron, downloadJob)
+	if err = c.AddJob(config.Cron, jobs); err != nil {
+		log.Error().Err(err).Msg("invalid cron")
+		return constants.ErrorConfiguration
+	}
+	probes = health.New(health.Checks{
+		State: jobs.State,
+		Next: func() time.Time {
+			if entries := c.Entries(); len(entries) > 0 {
+				return entries[0].Next
+			}
+			return time.Time{}
+		},
+		Gateway: func(ctx context.Context) error {
+			return host2host.ProbeGateway(ctx, config)
+		},
+	})
+	if err = host2host.PurgeWorkDirs(config); err != nil {
+		log.Error().Err(err).Msg("cannot purge working directories")
+	}
+	log.Info().Msgf("sftp service started... %s", config.Cron)
+	if config.ListenAddress != "" {
+		go serve(config, jobs)
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 	c.Start()
-	c.Run()
-	sig := make(chan os.Signal)
-	signal.Notify(sig, os.Interrupt, os.Kill)
-	<-sig
+	log.Info().Msgf("%s received, shutting down...", <-sig)
+
+	//no new run is scheduled; the running one gets ShutdownTimeout to finish, then it is cancelled and rolled back
+	c.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err = jobs.Stop(ctx); err != nil {
+		log.Warn().Msgf("run didn't finish within %s, unfinished files were rolled back", shutdownTimeout)
+	}
+	log.Info().Msg("sftp service stopped")
 	return constants.Ok
 }
 
-func downloadJob() {
-	err := ioutil.WriteFile("alive.txt", []byte("alive\n"), 0644)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to create working file")
-		return
+//schedule creates runner of downloads and reads ShutdownTimeout
+func schedule(config *conf.SftpConfig) (*runner.Runner, time.Duration, error) {
+	if _, err := cron.Parse(config.Cron); err != nil {
+		return nil, 0, errors.Wrapf(err, "invalid Cron %s", config.Cron)
 	}
-	if isDownloading {
-		log.Info().Msg("download process remains...")
-		return
+	shutdownTimeout := defaultShutdownTimeout
+	if config.ShutdownTimeout != "" {
+		var err error
+		if shutdownTimeout, err = time.ParseDuration(config.ShutdownTimeout); err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid ShutdownTimeout %s", config.ShutdownTimeout)
+		}
 	}
-	isDownloading = true
-	log.Info().Msg("downloading started...")
-	if code, err := download(); err != nil {
-		log.Error().Err(err).Msg("failed to establish etl client")
-		if code != constants.Ok {
-			isDownloading = false
-			return
+	jobs, err := runner.New(context.Background(), config.Overlap, downloadJob)
+	return jobs, shutdownTimeout, err
+}
+
+//downloadJob is executed by runner, which never lets two downloads overlap
+func downloadJob(ctx context.Context) error {
+	log.Info().Msgf("downloading started... run %s", runner.ID(ctx))
+	start := time.Now()
+	_, err := download(ctx)
+	metrics.Run(start, err)
+	return err
+}
+
+//serve runs embedded HTTP server
+func serve(config *conf.SftpConfig, jobs *runner.Runner) {
+	mux := http.NewServeMux()
+	probes.Register(mux)
+	mux.Handle(metrics.Path, metrics.Handler())
+	if config.AdminTokenFile != "" {
+		if token, err := config.AdminToken(); err != nil {
+			log.Error().Err(err).Msg("admin API and outbox upload are disabled")
+		} else {
+			mux.Handle(admin.Prefix, admin.Handler(token, admin.New(config, jobs)))
+			if config.OutboxPath != "" {
+				mux.Handle(outbox.Prefix, outbox.Handler(token, config.OutboxPath))
+			}
 		}
 	}
-	isDownloading = false
+	log.Info().Msgf("http server listening on %s", config.ListenAddress)
+	if err := http.ListenAndServe(config.ListenAddress, mux); err != nil {
+		log.Error().Err(err).Msg("http server failed")
+	}
 }
 
-func download() (int, error) {
-	fetched, err := host2host.Download(config)
+func download(ctx context.Context) (code int, err error) {
+	ctx, span := tracing.Start(ctx, "run", tracing.KeyRunID.String(runner.ID(ctx)))
+	defer func() { tracing.End(span, err) }()
+	fetched, err := host2host.Download(ctx, config, session)
+	probes.ReportConnection(err)
+	//outbound files are pushed even when download failed
+	uploads, pushErr := host2host.Push(ctx, config)
+	if pushErr != nil {
+		log.Error().Err(pushErr).Msg("failed to push outbound files")
+	}
+	for _, upload := range uploads {
+		if upload.Error != nil {
+			log.Error().Err(upload.Error).Msgf("error pushing file %s", upload.LocalPath)
+		}
+	}
 	if err != nil {
 		return constants.ErrorEstablishedConnection, errors.Wrap(err, "failed to establish etl client")
 	}
 	for _, fetch := range fetched {
+		if fetch.DuplicateOf != "" {
+			log.Warn().Msgf("duplicate %s skipped, content already delivered as %s", fetch.SourcePathOriginal, fetch.DuplicateOf)
+		}
 		if fetch.Error != nil {
 			err = errors.Wrapf(fetch.Error, "error processing file %s \n", fetch.SourcePathOriginal)
 		}
@@ -74,5 +170,5 @@ func download() (int, error) {
 }
 
 func main() {
-	os.Exit(app{}.Run())
+	os.Exit(app{}.Main(os.Args[1:]))
 }
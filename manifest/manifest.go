@@ -0,0 +1,134 @@
+package manifest
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// error messages
+const (
+	ErrInvalidLine = "invalid manifest line "
+	ErrEmpty       = "manifest is empty "
+)
+
+//Manifest maps file name to expected lowercase hex SHA-256 hash
+type Manifest map[string]string
+
+//ValidationError lists files which don't correspond to manifest
+type ValidationError struct {
+	Missing    []string
+	Mismatched []string
+	Unexpected []string
+}
+
+func (e *ValidationError) Error() string {
+	var reasons []string
+	if len(e.Missing) > 0 {
+		reasons = append(reasons, "missing: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Mismatched) > 0 {
+		reasons = append(reasons, "checksum mismatch: "+strings.Join(e.Mismatched, ", "))
+	}
+	if len(e.Unexpected) > 0 {
+		reasons = append(reasons, "unexpected: "+strings.Join(e.Unexpected, ", "))
+	}
+	return "manifest validation failed; " + strings.Join(reasons, "; ")
+}
+
+//Read reads manifest from file in `sha256sum` format
+func Read(file string) (Manifest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := Parse(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read manifest %s", file)
+	}
+	return m, nil
+}
+
+//Parse parses `sha256sum` format, i.e. `<hash>  <name>` or `<hash> *<name>` per line.
+//Empty lines and lines starting by # are ignored
+func Parse(r io.Reader) (Manifest, error) {
+	m := Manifest{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, errors.New(ErrInvalidLine + line)
+		}
+		hash := strings.ToLower(fields[0])
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, errors.New(ErrInvalidLine + line)
+		}
+		name := strings.TrimPrefix(strings.TrimSpace(line[len(fields[0]):]), "*")
+		m[strings.TrimPrefix(name, "./")] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, errors.New(ErrEmpty)
+	}
+	return m, nil
+}
+
+//HashFile returns lowercase hex SHA-256 of file
+func HashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return Hash(f)
+}
+
+//Hash returns lowercase hex SHA-256 of content read from r
+func Hash(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//Verify checks that actual hashes of delivered files correspond to manifest. Every listed file must exist
+//with the same hash and no other file is allowed
+func (m Manifest) Verify(actual Manifest) error {
+	verr := &ValidationError{}
+	for name, expected := range m {
+		hash, ok := actual[name]
+		if !ok {
+			verr.Missing = append(verr.Missing, name)
+			continue
+		}
+		if hash != expected {
+			verr.Mismatched = append(verr.Mismatched, name)
+		}
+	}
+	for name := range actual {
+		if _, ok := m[name]; !ok {
+			verr.Unexpected = append(verr.Unexpected, name)
+		}
+	}
+	if len(verr.Missing)+len(verr.Mismatched)+len(verr.Unexpected) == 0 {
+		return nil
+	}
+	sort.Strings(verr.Missing)
+	sort.Strings(verr.Mismatched)
+	sort.Strings(verr.Unexpected)
+	return verr
+}
@@ -0,0 +1,99 @@
+package manifest
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testData = struct {
+	InPath string
+}{
+	filepath.Join("testdata", "in"),
+}
+
+func TestReadManifest(t *testing.T) {
+	//arrange
+
+	//act
+	m, err := Read(filepath.Join(testData.InPath, "MANIFEST.sha256"))
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(m))
+	assert.Equal(t, "f14676f23af5b216b73c73c229fd99783e95a39a9bdd83c7721364d9a76cb8c9", m["warrants.csv"])
+}
+
+func TestParseInvalidManifest(t *testing.T) {
+	//arrange
+	content := "f14676f2 warrants.csv"
+
+	//act
+	m, err := Parse(strings.NewReader(content))
+
+	//assert
+	assert.Nil(t, m)
+	assert.Error(t, err)
+}
+
+func TestParseEmptyManifest(t *testing.T) {
+	//arrange
+	content := "# no files\n\n"
+
+	//act
+	_, err := Parse(strings.NewReader(content))
+
+	//assert
+	assert.EqualError(t, err, ErrEmpty)
+}
+
+func TestVerifySuccessfully(t *testing.T) {
+	//arrange
+	m, err := Read(filepath.Join(testData.InPath, "MANIFEST.sha256"))
+	assert.NoError(t, err)
+
+	//act
+	err = m.Verify(testFiles(t, "warrants.csv", "terms.xml"))
+
+	//assert
+	assert.NoError(t, err)
+}
+
+func TestVerifyTamperedFile(t *testing.T) {
+	//arrange
+	m, err := Read(filepath.Join(testData.InPath, "tampered.sha256"))
+	assert.NoError(t, err)
+
+	//act
+	err = m.Verify(testFiles(t, "warrants.csv", "terms.xml"))
+
+	//assert
+	assert.Error(t, err)
+	assert.Equal(t, []string{"terms.xml"}, err.(*ValidationError).Mismatched)
+}
+
+func TestVerifyIncompleteDelivery(t *testing.T) {
+	//arrange
+	m, err := Read(filepath.Join(testData.InPath, "MANIFEST.sha256"))
+	assert.NoError(t, err)
+
+	//act
+	err = m.Verify(testFiles(t, "warrants.csv", "tampered.sha256"))
+
+	//assert
+	assert.Error(t, err)
+	assert.Equal(t, []string{"terms.xml"}, err.(*ValidationError).Missing)
+	assert.Equal(t, []string{"tampered.sha256"}, err.(*ValidationError).Unexpected)
+}
+
+func testFiles(t *testing.T, names ...string) Manifest {
+	files := Manifest{}
+	for _, name := range names {
+		hash, err := HashFile(filepath.Join(testData.InPath, name))
+		assert.NoError(t, err)
+		files[name] = hash
+	}
+	return files
+}
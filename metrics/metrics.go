@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//Path is URL path metrics are served at
+const Path = "/metrics"
+
+//namespace prefixes names of all metrics
+const namespace = "edt_sftp"
+
+// stages files pass through
+const (
+	StageDiscover = "discover"
+	StageDownload = "download"
+	StageDecrypt  = "decrypt"
+	StageUnzip    = "unzip"
+	StageValidate = "validate"
+	StageSend     = "send"
+	StageRespond  = "respond"
+	StageClean    = "clean"
+	StageUpload   = "upload"
+)
+
+// results of stages and runs
+const (
+	ResultOK     = "ok"
+	ResultFailed = "failed"
+)
+
+// directions of transferred bytes
+const (
+	DirectionDownload = "download"
+	DirectionUpload   = "upload"
+)
+
+var (
+	files = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "files_total",
+		Help:      "Files which passed or failed stage, by partner.",
+	}, []string{"partner", "stage", "result"})
+
+	transferred = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transferred_bytes_total",
+		Help:      "Bytes downloaded from and uploaded to remote, by partner.",
+	}, []string{"partner", "direction"})
+
+	stageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stage_duration_seconds",
+		Help:      "Duration of stage over all files of the run.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"stage"})
+
+	gatewayResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gateway_responses_total",
+		Help:      "Responses of api-gateway by HTTP status code.",
+	}, []string{"code"})
+
+	runDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of scheduled runs.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 4, 10),
+	}, []string{"result"})
+
+	lastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last run which finished without error.",
+	})
+
+	stuck = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stuck_files",
+		Help:      "Files renamed to .edt found on remote by the last listing, by partner.",
+	}, []string{"partner"})
+)
+
+//Registry keeps all metrics of the service
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(files, transferred, stageDuration, gatewayResponses, runDuration, lastSuccess, stuck)
+	Registry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+}
+
+//Handler serves metrics in Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+//File counts file of partner which passed stage, or failed it when err is not nil
+func File(partner string, stage string, err error) {
+	files.WithLabelValues(partner, stage, result(err)).Inc()
+}
+
+//Transferred counts bytes of partner moved in direction
+func Transferred(partner string, direction string, bytes int64) {
+	transferred.WithLabelValues(partner, direction).Add(float64(bytes))
+}
+
+//Stage observes time stage took since start
+func Stage(stage string, start time.Time) {
+	stageDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+}
+
+//GatewayResponse counts response of api-gateway
+func GatewayResponse(statusCode int) {
+	gatewayResponses.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+}
+
+//Run observes duration of run started at start; run finished without error updates time of the last success
+func Run(start time.Time, err error) {
+	now := time.Now()
+	runDuration.WithLabelValues(result(err)).Observe(now.Sub(start).Seconds())
+	if err == nil {
+		lastSuccess.Set(float64(now.Unix()))
+	}
+}
+
+//Stuck sets number of .edt files per partner found on remote. Series of partners which are not listed are removed
+func Stuck(byPartner map[string]int) {
+	stuck.Reset()
+	for partner, count := range byPartner {
+		stuck.WithLabelValues(partner).Set(float64(count))
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultFailed
+	}
+	return ResultOK
+}
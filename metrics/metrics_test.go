@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCountsResultByPartnerAndStage(t *testing.T) {
+	//arrange
+	files.Reset()
+
+	//act
+	File("COBA", StageUnzip, nil)
+	File("COBA", StageUnzip, errors.New("corrupted"))
+	File("COBA", StageUnzip, nil)
+
+	//assert
+	assert.Equal(t, 2.0, testutil.ToFloat64(files.WithLabelValues("COBA", StageUnzip, ResultOK)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(files.WithLabelValues("COBA", StageUnzip, ResultFailed)))
+}
+
+func TestRunSetsLastSuccessOnlyWithoutError(t *testing.T) {
+	//arrange
+	lastSuccess.Set(0)
+
+	//act
+	Run(time.Now(), errors.New("failed"))
+	failed := testutil.ToFloat64(lastSuccess)
+	Run(time.Now(), nil)
+
+	//assert
+	assert.Equal(t, 0.0, failed)
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(lastSuccess), 1)
+}
+
+func TestStuckRemovesPartnersNotListed(t *testing.T) {
+	//arrange
+	Stuck(map[string]int{"COBA": 2, "BRCLS": 1})
+
+	//act
+	Stuck(map[string]int{"COBA": 1})
+
+	//assert
+	assert.Equal(t, 1, testutil.CollectAndCount(stuck))
+	assert.Equal(t, 1.0, testutil.ToFloat64(stuck.WithLabelValues("COBA")))
+}
+
+func TestHandlerServesMetrics(t *testing.T) {
+	//arrange
+	GatewayResponse(422)
+	recorder := httptest.NewRecorder()
+
+	//act
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", Path, nil))
+
+	//assert
+	body, _ := ioutil.ReadAll(recorder.Body)
+	assert.Equal(t, 200, recorder.Code)
+	assert.Contains(t, string(body), `edt_sftp_gateway_responses_total{code="422"}`)
+}
@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+//Prefix is URL path the handler is mounted at
+const Prefix = "/outbox/"
+
+//maxUpload limits size of file accepted by handler
+const maxUpload = 1 << 30
+
+//Handler accepts files for partners by `PUT` or `POST` of raw body to /outbox/<partner>/<name>.
+//Files are stored into outbox dir and uploaded by the next push. Requests must carry `Authorization: Bearer <token>`
+func Handler(token string, dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPut && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		segments := strings.Split(strings.TrimPrefix(r.URL.Path, Prefix), "/")
+		if len(segments) != 2 {
+			http.Error(w, "expected "+Prefix+"<partner>/<name>", http.StatusBadRequest)
+			return
+		}
+		stored, err := Write(dir, segments[0], segments[1], http.MaxBytesReader(w, r.Body, maxUpload))
+		if err != nil {
+			log.Error().Err(err).Msgf("cannot store %s into outbox", r.URL.Path)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Info().Msgf("outbox accepted %s", stored)
+		w.WriteHeader(http.StatusCreated)
+	})
+}
+
+func authorized(r *http.Request, token string) bool {
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
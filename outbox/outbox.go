@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// folders inside partner folder of outbox
+const (
+	//SentDir keeps files uploaded to partner until their acknowledge is collected or times out
+	SentDir = ".sent"
+	//AcksDir keeps collected acknowledges of partner
+	AcksDir = ".acks"
+)
+
+//tmpSuffix marks files which are being written into outbox
+const tmpSuffix = ".tmp"
+
+// error messages
+const (
+	ErrInvalidName = "invalid outbox name "
+)
+
+//File is file waiting in outbox, i.e. <outbox>/<partner>/<name>
+type File struct {
+	Partner string
+	Name    string
+	Path    string
+	Size    int64
+}
+
+//Scan returns files ready for upload. Files in the outbox root, hidden files and files with .tmp suffix are skipped
+func Scan(dir string) ([]File, error) {
+	var files []File
+	partners, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read outbox %s", dir)
+	}
+	for _, partner := range partners {
+		if !partner.IsDir() || hidden(partner.Name()) {
+			continue
+		}
+		entries, err := ioutil.ReadDir(filepath.Join(dir, partner.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read outbox of %s", partner.Name())
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || hidden(entry.Name()) || strings.HasSuffix(entry.Name(), tmpSuffix) {
+				continue
+			}
+			files = append(files, File{
+				Partner: partner.Name(),
+				Name:    entry.Name(),
+				Path:    filepath.Join(dir, partner.Name(), entry.Name()),
+				Size:    entry.Size(),
+			})
+		}
+	}
+	return files, nil
+}
+
+//MarkSent moves uploaded file into sent folder of partner. Modification time is set to now,
+//it is the time acknowledge timeout is counted from
+func MarkSent(dir string, file File) (string, error) {
+	sent := filepath.Join(dir, file.Partner, SentDir)
+	if err := os.MkdirAll(sent, os.ModePerm); err != nil {
+		return "", err
+	}
+	target := filepath.Join(sent, file.Name)
+	if err := os.Rename(file.Path, target); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return target, os.Chtimes(target, now, now)
+}
+
+//Write stores content into outbox of partner. Content is written into temporary file first
+//and renamed, so Scan never sees incomplete file
+func Write(dir string, partner string, name string, r io.Reader) (string, error) {
+	if err := validName(partner); err != nil {
+		return "", err
+	}
+	if err := validName(name); err != nil {
+		return "", err
+	}
+	folder := filepath.Join(dir, partner)
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(folder, "."+name+"-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", errors.Wrapf(err, "cannot write %s", name)
+	}
+	if err = tmp.Close(); err != nil {
+		return "", err
+	}
+	target := filepath.Join(folder, name)
+	return target, os.Rename(tmp.Name(), target)
+}
+
+//WriteAck stores acknowledge collected from partner
+func WriteAck(dir string, partner string, name string, content []byte) (string, error) {
+	acks := filepath.Join(dir, partner, AcksDir)
+	if err := os.MkdirAll(acks, os.ModePerm); err != nil {
+		return "", err
+	}
+	target := filepath.Join(acks, name)
+	return target, ioutil.WriteFile(target, content, 0644)
+}
+
+func validName(name string) error {
+	if name == "" || hidden(name) || strings.ContainsAny(name, `/\`) || strings.HasSuffix(name, tmpSuffix) {
+		return errors.New(ErrInvalidName + name)
+	}
+	return nil
+}
+
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
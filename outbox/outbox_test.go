@@ -0,0 +1,103 @@
+package outbox
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var testData = struct {
+	OutPath string
+}{
+	filepath.Join("testdata", "out"),
+}
+
+func TestMain(m *testing.M) {
+	//if anything failed before and files are still present
+	os.RemoveAll(filepath.Join(testData.OutPath, "outbox"))
+	utils.RemoveAllExcept(filepath.Join(testData.OutPath, ".gitkeep"))
+	m.Run()
+	//cleaning
+	os.RemoveAll(filepath.Join(testData.OutPath, "outbox"))
+}
+
+func TestWriteScanAndMarkSent(t *testing.T) {
+	//arrange
+	dir := filepath.Join(testData.OutPath, "outbox", "scan")
+	_, err := Write(dir, "COBA", "REPORT_180808.csv", strings.NewReader("ISIN;STATUS\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "COBA", "REPORT_180809.csv.tmp"), nil, 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ROOT.csv"), nil, 0644))
+
+	//act
+	files, err := Scan(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	sentPath, sentErr := MarkSent(dir, files[0])
+	rescanned, rescanErr := Scan(dir)
+	pending, listErr := Pending(dir)
+
+	//assert
+	assert.NoError(t, sentErr)
+	assert.NoError(t, rescanErr)
+	assert.NoError(t, listErr)
+	assert.Equal(t, File{Partner: "COBA", Name: "REPORT_180808.csv", Path: filepath.Join(dir, "COBA", "REPORT_180808.csv"), Size: 12}, files[0])
+	assert.Equal(t, filepath.Join(dir, "COBA", SentDir, "REPORT_180808.csv"), sentPath)
+	assert.Empty(t, rescanned)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "COBA", pending[0].Partner)
+	assert.Equal(t, sentPath, pending[0].Path)
+	assert.WithinDuration(t, time.Now(), pending[0].SentAt, time.Minute)
+}
+
+func TestWriteRefusesInvalidNames(t *testing.T) {
+	dir := filepath.Join(testData.OutPath, "outbox", "invalid")
+	for _, name := range [][2]string{{"COBA", "../passwd"}, {"..", "x.csv"}, {"COBA", ".hidden"}, {"COBA", "x.csv.tmp"}, {"", "x.csv"}} {
+		//arrange
+		//act
+		_, err := Write(dir, name[0], name[1], strings.NewReader(""))
+
+		//assert
+		assert.Error(t, err, name)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	//arrange
+	dir := filepath.Join(testData.OutPath, "outbox", "http")
+	server := httptest.NewServer(Handler("secret", dir))
+	defer server.Close()
+	send := func(method string, path string, token string) (*http.Response, error) {
+		request, _ := http.NewRequest(method, server.URL+Prefix+path, strings.NewReader("ISIN;STATUS\n"))
+		request.Header.Set("Authorization", "Bearer "+token)
+		return http.DefaultClient.Do(request)
+	}
+
+	//act
+	response, err := send(http.MethodPut, "BRCLS/REPORT_180808.csv", "secret")
+	invalid, invalidErr := send(http.MethodPost, "BRCLS", "secret")
+	get, getErr := send(http.MethodGet, "BRCLS/REPORT_180808.csv", "secret")
+	unauthorized, unauthorizedErr := send(http.MethodPut, "BRCLS/REPORT_180809.csv", "wrong")
+
+	//assert
+	assert.NoError(t, err)
+	assert.NoError(t, invalidErr)
+	assert.NoError(t, getErr)
+	assert.NoError(t, unauthorizedErr)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, http.StatusBadRequest, invalid.StatusCode)
+	assert.Equal(t, http.StatusMethodNotAllowed, get.StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, unauthorized.StatusCode)
+	assert.NoFileExists(t, filepath.Join(dir, "BRCLS", "REPORT_180809.csv"))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "BRCLS", "REPORT_180808.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ISIN;STATUS\n", string(content))
+}
@@ -0,0 +1,167 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// folders inside partner folder of outbox for resolved files
+const (
+	//DoneDir keeps files acknowledged by partner
+	DoneDir = ".done"
+	//RejectedDir keeps files refused by partner
+	RejectedDir = ".rejected"
+	//TimeoutDir keeps files partner didn't acknowledge in time
+	TimeoutDir = ".timeout"
+)
+
+// acknowledge statuses
+const (
+	StatusOK       = "ok"
+	StatusRejected = "rejected"
+	StatusTimeout  = "timeout"
+)
+
+// error messages
+const (
+	ErrInvalidAck = "invalid acknowledge "
+	ErrAckOfOther = "acknowledge names other file "
+)
+
+//Ack is parsed acknowledge of partner
+type Ack struct {
+	File      string `json:"file"`
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+	Reason    string `json:"reason"`
+}
+
+//Accepted returns true if partner accepted the file
+func (a Ack) Accepted() bool {
+	return a.Status == StatusOK
+}
+
+//ParseAck parses acknowledge of partner. JSON `{"file": ..., "status": ..., "timestamp": ..., "reason": ...}`
+//and `<file>;<timestamp>[;<status>[;<reason>]]` are supported. Missing status means ok, statuses other than
+//ok, accepted or duplicate mean the file was rejected
+func ParseAck(content []byte) (Ack, error) {
+	content = bytes.TrimSpace(content)
+	ack := Ack{}
+	if bytes.HasPrefix(content, []byte("{")) {
+		if err := json.Unmarshal(content, &ack); err != nil {
+			return ack, errors.Wrap(err, ErrInvalidAck)
+		}
+	} else {
+		fields := strings.SplitN(strings.SplitN(string(content), "\n", 2)[0], ";", 4)
+		if len(fields) < 2 || fields[0] == "" {
+			return ack, errors.New(ErrInvalidAck + string(content))
+		}
+		ack.File, ack.Timestamp = fields[0], fields[1]
+		if len(fields) > 2 {
+			ack.Status = fields[2]
+		}
+		if len(fields) > 3 {
+			ack.Reason = fields[3]
+		}
+	}
+	switch strings.ToLower(ack.Status) {
+	case "", StatusOK, "accepted", "duplicate":
+		ack.Status = StatusOK
+	default:
+		ack.Status = StatusRejected
+	}
+	return ack, nil
+}
+
+//Tracked is file uploaded to partner waiting for acknowledge
+type Tracked struct {
+	Partner string
+	Name    string
+	Path    string
+	Size    int64
+	//SentAt is time the file was uploaded
+	SentAt time.Time
+	//TimedOut is true for file already moved to timeout folder
+	TimedOut bool
+}
+
+//Expired returns true if acknowledge didn't come within timeout; zero timeout never expires
+func (t Tracked) Expired(timeout time.Duration, now time.Time) bool {
+	return timeout > 0 && now.Sub(t.SentAt) > timeout
+}
+
+//Pending returns files of all partners waiting for acknowledge
+func Pending(dir string) ([]Tracked, error) {
+	return tracked(dir, SentDir)
+}
+
+//TimedOut returns files of all partners moved to timeout folder, their acknowledge may still come
+func TimedOut(dir string) ([]Tracked, error) {
+	timedOut, err := tracked(dir, TimeoutDir)
+	for i := range timedOut {
+		timedOut[i].TimedOut = true
+	}
+	return timedOut, err
+}
+
+func tracked(dir string, folder string) ([]Tracked, error) {
+	var pending []Tracked
+	partners, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read outbox %s", dir)
+	}
+	for _, partner := range partners {
+		if !partner.IsDir() || hidden(partner.Name()) {
+			continue
+		}
+		entries, err := ioutil.ReadDir(filepath.Join(dir, partner.Name(), folder))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			pending = append(pending, Tracked{
+				Partner: partner.Name(),
+				Name:    entry.Name(),
+				Path:    filepath.Join(dir, partner.Name(), folder, entry.Name()),
+				Size:    entry.Size(),
+				SentAt:  entry.ModTime(),
+			})
+		}
+	}
+	return pending, nil
+}
+
+//Resolve moves tracked file to done or rejected folder according to acknowledge
+func Resolve(dir string, tracked Tracked, ack Ack) (string, error) {
+	if ack.Accepted() {
+		return move(dir, tracked, DoneDir)
+	}
+	return move(dir, tracked, RejectedDir)
+}
+
+//Expire moves tracked file which was not acknowledged in time to timeout folder
+func Expire(dir string, tracked Tracked) (string, error) {
+	return move(dir, tracked, TimeoutDir)
+}
+
+func move(dir string, tracked Tracked, folder string) (string, error) {
+	target := filepath.Join(dir, tracked.Partner, folder)
+	if err := os.MkdirAll(target, os.ModePerm); err != nil {
+		return "", err
+	}
+	target = filepath.Join(target, tracked.Name)
+	return target, os.Rename(tracked.Path, target)
+}
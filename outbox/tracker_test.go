@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAck(t *testing.T) {
+	//arrange
+	tests := []struct {
+		content string
+		ack     Ack
+	}{
+		{"REPORT.csv;2018-08-08T10:00:00Z\n", Ack{File: "REPORT.csv", Timestamp: "2018-08-08T10:00:00Z", Status: StatusOK}},
+		{"REPORT.csv;2018-08-08T10:00:00Z;DUPLICATE", Ack{File: "REPORT.csv", Timestamp: "2018-08-08T10:00:00Z", Status: StatusOK}},
+		{"REPORT.csv;2018-08-08T10:00:00Z;rejected;unknown ISIN", Ack{File: "REPORT.csv", Timestamp: "2018-08-08T10:00:00Z", Status: StatusRejected, Reason: "unknown ISIN"}},
+		{`{"file":"REPORT.csv","status":"failed","reason":"bad header"}`, Ack{File: "REPORT.csv", Status: StatusRejected, Reason: "bad header"}},
+	}
+	for _, test := range tests {
+		//act
+		ack, err := ParseAck([]byte(test.content))
+
+		//assert
+		assert.NoError(t, err, test.content)
+		assert.Equal(t, test.ack, ack, test.content)
+	}
+}
+
+func TestParseAckInvalid(t *testing.T) {
+	//act
+	_, plainErr := ParseAck([]byte("REPORT.csv"))
+	_, jsonErr := ParseAck([]byte("{file"))
+
+	//assert
+	assert.Error(t, plainErr)
+	assert.Error(t, jsonErr)
+}
+
+func TestExpired(t *testing.T) {
+	//arrange
+	now := time.Now()
+	tracked := Tracked{SentAt: now.Add(-2 * time.Hour)}
+
+	//assert
+	assert.True(t, tracked.Expired(time.Hour, now))
+	assert.False(t, tracked.Expired(3*time.Hour, now))
+	assert.False(t, tracked.Expired(0, now))
+}
+
+func TestResolveAndExpire(t *testing.T) {
+	//arrange
+	dir := filepath.Join(testData.OutPath, "outbox", "tracker")
+	for _, name := range []string{"ACCEPTED.csv", "REJECTED.csv", "LATE.csv"} {
+		_, err := Write(dir, "COBA", name, strings.NewReader("ISIN;STATUS\n"))
+		assert.NoError(t, err)
+	}
+	files, err := Scan(dir)
+	assert.NoError(t, err)
+	for _, file := range files {
+		_, err = MarkSent(dir, file)
+		assert.NoError(t, err)
+	}
+	pending, err := Pending(dir)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 3)
+	byName := map[string]Tracked{}
+	for _, tracked := range pending {
+		byName[tracked.Name] = tracked
+	}
+
+	//act
+	done, doneErr := Resolve(dir, byName["ACCEPTED.csv"], Ack{Status: StatusOK})
+	rejected, rejectedErr := Resolve(dir, byName["REJECTED.csv"], Ack{Status: StatusRejected})
+	expired, expiredErr := Expire(dir, byName["LATE.csv"])
+	left, leftErr := Pending(dir)
+
+	//assert
+	assert.NoError(t, doneErr)
+	assert.NoError(t, rejectedErr)
+	assert.NoError(t, expiredErr)
+	assert.NoError(t, leftErr)
+	assert.Empty(t, left)
+	for _, file := range []string{done, rejected, expired} {
+		_, err = os.Stat(file)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, filepath.Join(dir, "COBA", DoneDir, "ACCEPTED.csv"), done)
+	assert.Equal(t, filepath.Join(dir, "COBA", RejectedDir, "REJECTED.csv"), rejected)
+	assert.Equal(t, filepath.Join(dir, "COBA", TimeoutDir, "LATE.csv"), expired)
+}
+
+func TestLateAcknowledge(t *testing.T) {
+	//arrange
+	dir := filepath.Join(testData.OutPath, "outbox", "late")
+	_, err := Write(dir, "COBA", "LATE.csv", strings.NewReader("ISIN;STATUS\n"))
+	assert.NoError(t, err)
+	files, err := Scan(dir)
+	assert.NoError(t, err)
+	_, err = MarkSent(dir, files[0])
+	assert.NoError(t, err)
+	pending, err := Pending(dir)
+	assert.NoError(t, err)
+	_, err = Expire(dir, pending[0])
+	assert.NoError(t, err)
+
+	//act
+	timedOut, timedOutErr := TimedOut(dir)
+	done, doneErr := Resolve(dir, timedOut[0], Ack{Status: StatusOK})
+	left, leftErr := TimedOut(dir)
+
+	//assert
+	assert.NoError(t, timedOutErr)
+	assert.NoError(t, doneErr)
+	assert.NoError(t, leftErr)
+	assert.True(t, timedOut[0].TimedOut)
+	assert.Equal(t, "LATE.csv", timedOut[0].Name)
+	assert.Equal(t, filepath.Join(dir, "COBA", DoneDir, "LATE.csv"), done)
+	assert.Empty(t, left)
+}
@@ -0,0 +1,210 @@
+package pgp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/pkg/errors"
+)
+
+// error messages
+const (
+	ErrNoPrivateKey      = "no private key to decrypt message "
+	ErrNotEncrypted      = "message is not encrypted "
+	ErrMissingSignature  = "message is not signed and detached signature is missing "
+	ErrUnknownSigner     = "signed by key not present in partner keyring "
+	ErrInvalidSignature  = "invalid signature "
+	ErrEmptyKeyring      = "keyring is empty "
+	ErrInvalidPassphrase = "cannot decrypt private key with passphrase "
+	ErrReadKeyring       = "cannot read keyring "
+	ErrArmor             = "cannot decode armored message "
+	ErrDecrypt           = "cannot decrypt "
+)
+
+//Result describes decrypted message
+type Result struct {
+	//Signed is true if message carried embedded signature which was verified
+	Signed bool
+	//Signer is entity who signed the message
+	Signer *openpgp.Entity
+}
+
+//ReadKeyRing reads armored or binary keyring from file
+func ReadKeyRing(file string) (openpgp.EntityList, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	var entities openpgp.EntityList
+	if armored(reader) {
+		entities, err = openpgp.ReadArmoredKeyRing(reader)
+	} else {
+		entities, err = openpgp.ReadKeyRing(reader)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, ErrReadKeyring+file)
+	}
+	if len(entities) == 0 {
+		return nil, errors.New(ErrEmptyKeyring + file)
+	}
+	return entities, nil
+}
+
+//ReadPrivateKey reads private keyring and decrypts private keys by passphrase (if protected)
+func ReadPrivateKey(file string, passphrase []byte) (openpgp.EntityList, error) {
+	entities, err := ReadKeyRing(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		if entity.PrivateKey != nil && entity.PrivateKey.Encrypted {
+			if err = entity.PrivateKey.Decrypt(passphrase); err != nil {
+				return nil, errors.Wrap(err, ErrInvalidPassphrase)
+			}
+		}
+		for _, subkey := range entity.Subkeys {
+			if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+				if err = subkey.PrivateKey.Decrypt(passphrase); err != nil {
+					return nil, errors.Wrap(err, ErrInvalidPassphrase)
+				}
+			}
+		}
+	}
+	return entities, nil
+}
+
+//Decrypt decrypts src into dst by private key. If message is signed, the signature is verified against keyring
+//of the partner only, so message signed by our own key is refused. Keyring may be empty for unsigned messages.
+//When any error occurs, dst is removed
+func Decrypt(src string, dst string, private openpgp.EntityList, keyring openpgp.EntityList) (result Result, err error) {
+	if len(private) == 0 {
+		return result, errors.New(ErrNoPrivateKey)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return result, err
+	}
+	defer in.Close()
+	reader := bufio.NewReader(in)
+	var body io.Reader = reader
+	if armored(reader) {
+		block, err := armor.Decode(reader)
+		if err != nil {
+			return result, errors.Wrap(err, ErrArmor+src)
+		}
+		body = block.Body
+	}
+
+	md, err := openpgp.ReadMessage(body, keys{private: private, keyring: keyring}, nil, nil)
+	if err != nil {
+		return result, errors.Wrap(err, ErrDecrypt+src)
+	}
+	if !md.IsEncrypted {
+		return result, errors.New(ErrNotEncrypted + src)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		cerr := out.Close()
+		if err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+	if _, err = io.Copy(out, md.UnverifiedBody); err != nil {
+		return result, errors.Wrap(err, ErrDecrypt+src)
+	}
+	if !md.IsSigned {
+		return result, nil
+	}
+	if md.SignedBy == nil {
+		return result, errors.Errorf("%s%X", ErrUnknownSigner, md.SignedByKeyId)
+	}
+	if md.SignatureError != nil {
+		return result, errors.Wrapf(md.SignatureError, "%s%s", ErrInvalidSignature, src)
+	}
+	result.Signed = true
+	result.Signer = md.SignedBy.Entity
+	return result, nil
+}
+
+//VerifyDetached verifies armored or binary detached signature of file against keyring
+func VerifyDetached(file string, signature string, keyring openpgp.EntityList) (*openpgp.Entity, error) {
+	signed, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer signed.Close()
+	sig, err := os.Open(signature)
+	if err != nil {
+		return nil, err
+	}
+	defer sig.Close()
+	return verifyDetached(signed, sig, signature, keyring)
+}
+
+//VerifyDetachedContent verifies armored or binary detached signature of content against keyring
+func VerifyDetachedContent(content []byte, signature []byte, keyring openpgp.EntityList) (*openpgp.Entity, error) {
+	return verifyDetached(bytes.NewReader(content), bytes.NewReader(signature), "", keyring)
+}
+
+//SignDetached creates armored detached signature of content by signer
+func SignDetached(content []byte, signer *openpgp.Entity) ([]byte, error) {
+	if signer == nil || signer.PrivateKey == nil {
+		return nil, errors.New(ErrNoPrivateKey)
+	}
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, signer, bytes.NewReader(content), nil); err != nil {
+		return nil, errors.Wrap(err, "cannot sign")
+	}
+	return signature.Bytes(), nil
+}
+
+func verifyDetached(signed io.Reader, sig io.Reader, name string, keyring openpgp.EntityList) (*openpgp.Entity, error) {
+	reader := bufio.NewReader(sig)
+	var signer *openpgp.Entity
+	var err error
+	if armored(reader) {
+		signer, err = openpgp.CheckArmoredDetachedSignature(keyring, signed, reader, nil)
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(keyring, signed, reader, nil)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s%s", ErrInvalidSignature, name)
+	}
+	return signer, nil
+}
+
+//keys offers private keys for decryption and partner keyring for signature verification
+type keys struct {
+	private openpgp.EntityList
+	keyring openpgp.EntityList
+}
+
+func (k keys) KeysById(id uint64) []openpgp.Key {
+	return k.private.KeysById(id)
+}
+
+func (k keys) KeysByIdUsage(id uint64, usage byte) []openpgp.Key {
+	return k.keyring.KeysByIdUsage(id, usage)
+}
+
+func (k keys) DecryptionKeys() []openpgp.Key {
+	return k.private.DecryptionKeys()
+}
+
+func armored(reader *bufio.Reader) bool {
+	prefix, _ := reader.Peek(len("-----BEGIN"))
+	return bytes.Equal(prefix, []byte("-----BEGIN"))
+}
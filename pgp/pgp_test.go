@@ -0,0 +1,205 @@
+package pgp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Deutsche-Boerse/edt-sftp/utils"
+)
+
+const (
+	tested     = "KV0011_T_EDT_Warrant01.zip"
+	hashSHA256 = 8
+)
+
+var testData = struct {
+	InPath  string
+	OutPath string
+}{
+	filepath.Join("testdata", "in"),
+	filepath.Join("testdata", "out"),
+}
+
+func TestDecryptSignedMessage(t *testing.T) {
+	//arrange
+	we, partner := newEntity(t, "edt"), newEntity(t, "partner")
+	src := encrypt(t, tested+".pgp", we, partner)
+	dst := filepath.Join(testData.OutPath, tested)
+
+	//act
+	result, err := Decrypt(src, dst, openpgp.EntityList{we}, openpgp.EntityList{partner})
+
+	//assert
+	assert.NoError(t, err)
+	assert.True(t, result.Signed)
+	assert.Equal(t, partner.PrimaryKey.KeyId, result.Signer.PrimaryKey.KeyId)
+	assertSameContent(t, filepath.Join(testData.InPath, tested), dst)
+}
+
+func TestDecryptMessageSignedByUnknownKey(t *testing.T) {
+	//arrange
+	we, partner, stranger := newEntity(t, "edt"), newEntity(t, "partner"), newEntity(t, "stranger")
+	src := encrypt(t, tested+".gpg", we, stranger)
+	dst := filepath.Join(testData.OutPath, tested)
+
+	//act
+	_, err := Decrypt(src, dst, openpgp.EntityList{we}, openpgp.EntityList{partner})
+
+	//assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ErrUnknownSigner)
+	exists, _ := utils.Exists(dst)
+	assert.False(t, exists, "decrypted file must be removed when verification fails")
+}
+
+func TestDecryptMessageSignedByOwnKey(t *testing.T) {
+	//arrange
+	we, partner := newEntity(t, "edt"), newEntity(t, "partner")
+	src := encrypt(t, tested+".pgp", we, we)
+	dst := filepath.Join(testData.OutPath, tested)
+
+	//act
+	_, err := Decrypt(src, dst, openpgp.EntityList{we}, openpgp.EntityList{partner})
+
+	//assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ErrUnknownSigner)
+}
+
+func TestDecryptUnsignedMessageWithoutKeyring(t *testing.T) {
+	//arrange
+	we := newEntity(t, "edt")
+	src := encrypt(t, tested+".pgp", we, nil)
+	dst := filepath.Join(testData.OutPath, tested)
+
+	//act
+	result, err := Decrypt(src, dst, openpgp.EntityList{we}, nil)
+
+	//assert
+	assert.NoError(t, err)
+	assert.False(t, result.Signed)
+	assertSameContent(t, filepath.Join(testData.InPath, tested), dst)
+}
+
+func TestDecryptWithoutPrivateKey(t *testing.T) {
+	//arrange
+	we, partner := newEntity(t, "edt"), newEntity(t, "partner")
+	src := encrypt(t, tested+".pgp", we, partner)
+
+	//act
+	_, err := Decrypt(src, filepath.Join(testData.OutPath, tested), nil, openpgp.EntityList{partner})
+
+	//assert
+	assert.EqualError(t, err, ErrNoPrivateKey)
+}
+
+func TestVerifyDetachedSignature(t *testing.T) {
+	//arrange
+	partner := newEntity(t, "partner")
+	file := filepath.Join(testData.InPath, tested)
+	signature := sign(t, file, partner)
+
+	//act
+	signer, err := VerifyDetached(file, signature, openpgp.EntityList{partner})
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, partner.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+}
+
+func TestVerifyDetachedSignatureOfTamperedFile(t *testing.T) {
+	//arrange
+	partner := newEntity(t, "partner")
+	signature := sign(t, filepath.Join(testData.InPath, tested), partner)
+	tampered := filepath.Join(testData.OutPath, tested)
+	assert.NoError(t, ioutil.WriteFile(tampered, []byte("tampered"), 0644))
+
+	//act
+	_, err := VerifyDetached(tampered, signature, openpgp.EntityList{partner})
+
+	//assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ErrInvalidSignature)
+}
+
+func TestReadKeyRing(t *testing.T) {
+	//arrange
+	partner := newEntity(t, "partner")
+	file := filepath.Join(testData.OutPath, "partner.asc")
+	out, err := os.Create(file)
+	assert.NoError(t, err)
+	w, err := armor.Encode(out, openpgp.PublicKeyType, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, partner.Serialize(w))
+	w.Close()
+	out.Close()
+
+	//act
+	keyring, err := ReadKeyRing(file)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(keyring))
+	assert.Equal(t, partner.PrimaryKey.KeyId, keyring[0].PrimaryKey.KeyId)
+}
+
+func TestMain(m *testing.M) {
+	//if anything failed before and files are still present
+	utils.RemoveAllExcept(filepath.Join(testData.OutPath, ".gitkeep"))
+	m.Run()
+	//cleaning
+	utils.RemoveAllExcept(filepath.Join(testData.OutPath, ".gitkeep"))
+}
+
+func newEntity(t *testing.T, name string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "test", name+"@example.com", nil)
+	assert.NoError(t, err)
+	//RIPEMD160 is used when no preference is set and it is not compiled in
+	for _, identity := range entity.Identities {
+		identity.SelfSignature.PreferredHash = []uint8{hashSHA256}
+	}
+	return entity
+}
+
+//encrypt encrypts testdata file to recipient and signs it by signer
+func encrypt(t *testing.T, name string, recipient *openpgp.Entity, signer *openpgp.Entity) string {
+	plain, err := ioutil.ReadFile(filepath.Join(testData.InPath, tested))
+	assert.NoError(t, err)
+	file := filepath.Join(testData.OutPath, name)
+	out, err := os.Create(file)
+	assert.NoError(t, err)
+	defer out.Close()
+	w, err := openpgp.Encrypt(out, openpgp.EntityList{recipient}, signer, nil, nil)
+	assert.NoError(t, err)
+	_, err = w.Write(plain)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return file
+}
+
+func sign(t *testing.T, file string, signer *openpgp.Entity) string {
+	in, err := os.Open(file)
+	assert.NoError(t, err)
+	defer in.Close()
+	signature := filepath.Join(testData.OutPath, filepath.Base(file)+".sig")
+	out, err := os.Create(signature)
+	assert.NoError(t, err)
+	defer out.Close()
+	assert.NoError(t, openpgp.ArmoredDetachSign(out, signer, in, nil))
+	return signature
+}
+
+func assertSameContent(t *testing.T, expected string, actual string) {
+	a, err := ioutil.ReadFile(expected)
+	assert.NoError(t, err)
+	b, err := ioutil.ReadFile(actual)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(a, b), "content of %s differs from %s", actual, expected)
+}
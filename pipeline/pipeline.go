@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client"
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/Deutsche-Boerse/edt-sftp/metrics"
+	"github.com/Deutsche-Boerse/edt-sftp/tracing"
+	"github.com/pkg/errors"
+)
+
+// error messages
+const (
+	ErrDownload string = "failed downloading "
+	ErrDecrypt  string = "failed decrypting "
+	ErrUnzip    string = "failed unzipping "
+	ErrValidate string = "failed validating "
+	ErrResponse string = "failed sending response "
+	ErrClean    string = "failed cleaning "
+	ErrRollback string = "failed rolling back "
+)
+
+//Complete processes downloaded files. Interrupted run finishes files already sent to api-gateway and rolls back
+//the others, so no file stays renamed to .edt and no file is sent twice
+func Complete(ctx context.Context, c client.Client, downloads []*structs.DownloadInfo, err error) ([]*structs.DownloadInfo, error) {
+	if err != nil {
+		err = errors.New(ErrDownload + err.Error())
+	} else {
+		err = process(ctx, c, downloads)
+	}
+	if err == nil || ctx.Err() == nil || len(downloads) == 0 {
+		return downloads, err
+	}
+	var sent, unsent []*structs.DownloadInfo
+	for _, download := range downloads {
+		if download.Sent {
+			sent = append(sent, download)
+		} else {
+			unsent = append(unsent, download)
+		}
+	}
+	if len(sent) > 0 {
+		if finishErr := finish(context.WithoutCancel(ctx), c, sent); finishErr != nil {
+			return downloads, finishErr
+		}
+	}
+	if len(unsent) > 0 {
+		if rollbackErr := c.Rollback(unsent); rollbackErr != nil {
+			return downloads, errors.New(ErrRollback + rollbackErr.Error())
+		}
+	}
+	return downloads, err
+}
+
+//finish responds and cleans files sent before the run was interrupted, ctx must not be cancelled
+func finish(ctx context.Context, c client.Client, sent []*structs.DownloadInfo) error {
+	if err := measure(ctx, metrics.StageRespond, sent, c.SendResponses); err != nil {
+		return errors.New(ErrResponse + err.Error())
+	}
+	if err := measure(ctx, metrics.StageClean, sent, c.Clean); err != nil {
+		return errors.New(ErrClean + err.Error())
+	}
+	return nil
+}
+
+//process runs downloaded files through all stages
+func process(ctx context.Context, c client.Client, downloads []*structs.DownloadInfo) error {
+	stages := []struct {
+		name   string
+		errMsg string
+		run    func(context.Context, []*structs.DownloadInfo) error
+	}{
+		{metrics.StageDecrypt, ErrDecrypt, c.Decrypt},
+		{metrics.StageUnzip, ErrUnzip, c.Unzip},
+		{metrics.StageValidate, ErrValidate, c.Validate},
+		{metrics.StageSend, ErrResponse, c.SendToEdt},
+		{metrics.StageRespond, ErrResponse, c.SendResponses},
+		{metrics.StageClean, ErrClean, c.Clean},
+	}
+	for _, stage := range stages {
+		if err := measure(ctx, stage.name, downloads, stage.run); err != nil {
+			return errors.New(stage.errMsg + err.Error())
+		}
+	}
+	return nil
+}
+
+//measure runs stage in its own span and observes its duration. Files which entered the stage without error
+//are counted as failed when the stage failed them or failed as a whole
+func measure(ctx context.Context, stage string, downloads []*structs.DownloadInfo,
+	run func(context.Context, []*structs.DownloadInfo) error) error {
+	ctx, span := tracing.Start(ctx, stage)
+	entered := make([]*structs.DownloadInfo, 0, len(downloads))
+	for _, download := range downloads {
+		if download.Error == nil {
+			entered = append(entered, download)
+		}
+	}
+	start := time.Now()
+	err := run(ctx, downloads)
+	metrics.Stage(stage, start)
+	tracing.End(span, err)
+	for _, download := range entered {
+		stageErr := download.Error
+		if stageErr == nil {
+			stageErr = err
+		}
+		metrics.File(download.Partner, stage, stageErr)
+	}
+	return err
+}
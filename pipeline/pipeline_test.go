@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Deutsche-Boerse/edt-sftp/client"
+	"github.com/Deutsche-Boerse/edt-sftp/client/structs"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeClient records stages it ran; methods not used by pipeline panic on the embedded nil client
+type fakeClient struct {
+	client.Client
+	stages []string
+	//cancelled tells per stage whether its context was already done
+	cancelled map[string]bool
+	//files tells per stage which files it was given
+	files map[string][]string
+	//send runs in SendToEdt, i.e. to interrupt the run
+	send func(ctx context.Context, downloads []*structs.DownloadInfo) error
+	//failClean fails Clean
+	failClean error
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{cancelled: map[string]bool{}, files: map[string][]string{}}
+}
+
+func (c *fakeClient) run(ctx context.Context, stage string, downloads []*structs.DownloadInfo) {
+	c.stages = append(c.stages, stage)
+	c.cancelled[stage] = ctx.Err() != nil
+	for _, download := range downloads {
+		c.files[stage] = append(c.files[stage], download.SourcePathOriginal)
+	}
+}
+
+func (c *fakeClient) Decrypt(ctx context.Context, downloads []*structs.DownloadInfo) error {
+	c.run(ctx, "decrypt", downloads)
+	return nil
+}
+
+func (c *fakeClient) Unzip(ctx context.Context, downloads []*structs.DownloadInfo) error {
+	c.run(ctx, "unzip", downloads)
+	return nil
+}
+
+func (c *fakeClient) Validate(ctx context.Context, downloads []*structs.DownloadInfo) error {
+	c.run(ctx, "validate", downloads)
+	return nil
+}
+
+func (c *fakeClient) SendToEdt(ctx context.Context, downloads []*structs.DownloadInfo) error {
+	c.run(ctx, "send", downloads)
+	if c.send != nil {
+		return c.send(ctx, downloads)
+	}
+	for _, download := range downloads {
+		download.Sent = true
+	}
+	return nil
+}
+
+func (c *fakeClient) SendResponses(ctx context.Context, downloads []*structs.DownloadInfo) error {
+	c.run(ctx, "respond", downloads)
+	return nil
+}
+
+func (c *fakeClient) Clean(ctx context.Context, downloads []*structs.DownloadInfo) error {
+	c.run(ctx, "clean", downloads)
+	return c.failClean
+}
+
+func (c *fakeClient) Rollback(downloads []*structs.DownloadInfo) error {
+	c.run(context.Background(), "rollback", downloads)
+	return nil
+}
+
+func testDownloads() []*structs.DownloadInfo {
+	return []*structs.DownloadInfo{
+		{Partner: "COBA", SourcePathOriginal: "/home/ec2-user/COBA/KV1212_T_EDT_Bonds180808.zip"},
+		{Partner: "BRCLS", SourcePathOriginal: "/home/ec2-user/BRCLS/KV1212_T_EDT_Bonds180809.zip"},
+	}
+}
+
+//interruptAfterFirst sends the first file and cancels the run before the others are sent
+func interruptAfterFirst(cancel context.CancelFunc) func(context.Context, []*structs.DownloadInfo) error {
+	return func(ctx context.Context, downloads []*structs.DownloadInfo) error {
+		downloads[0].Sent = true
+		cancel()
+		return ctx.Err()
+	}
+}
+
+func TestCompleteRunsAllStages(t *testing.T) {
+	//arrange
+	c := newFakeClient()
+	downloads := testDownloads()
+
+	//act
+	completed, err := Complete(context.Background(), c, downloads, nil)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, downloads, completed)
+	assert.Equal(t, []string{"decrypt", "unzip", "validate", "send", "respond", "clean"}, c.stages)
+}
+
+func TestCompleteReturnsDownloadErrorWithoutProcessing(t *testing.T) {
+	//arrange
+	c := newFakeClient()
+
+	//act
+	_, err := Complete(context.Background(), c, testDownloads(), errors.New("connection lost"))
+
+	//assert
+	assert.EqualError(t, err, ErrDownload+"connection lost")
+	assert.Empty(t, c.stages)
+}
+
+func TestInterruptedRunFinishesSentAndRollsBackUnsent(t *testing.T) {
+	//arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newFakeClient()
+	c.send = interruptAfterFirst(cancel)
+	downloads := testDownloads()
+
+	//act
+	_, err := Complete(ctx, c, downloads, nil)
+
+	//assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+	assert.Equal(t, []string{"decrypt", "unzip", "validate", "send", "respond", "clean", "rollback"}, c.stages)
+	assert.Equal(t, []string{downloads[0].SourcePathOriginal}, c.files["respond"])
+	assert.Equal(t, []string{downloads[0].SourcePathOriginal}, c.files["clean"])
+	assert.Equal(t, []string{downloads[1].SourcePathOriginal}, c.files["rollback"])
+	assert.False(t, c.cancelled["respond"], "sent files must be responded with context which is not cancelled")
+	assert.False(t, c.cancelled["clean"], "sent files must be cleaned with context which is not cancelled")
+}
+
+func TestFailedFinishKeepsUnsentRenamed(t *testing.T) {
+	//arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newFakeClient()
+	c.send = interruptAfterFirst(cancel)
+	c.failClean = errors.New("permission denied")
+
+	//act
+	_, err := Complete(ctx, c, testDownloads(), nil)
+
+	//assert
+	assert.EqualError(t, err, ErrClean+"permission denied")
+	assert.NotContains(t, c.stages, "rollback")
+}
@@ -0,0 +1,290 @@
+package readiness
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// strategy names
+const (
+	StrategyMarker    = "marker"
+	StrategyExtension = "extension"
+	StrategyStable    = "stable"
+	StrategyAge       = "age"
+	StrategyManifest  = "manifest"
+	StrategyNone      = "none"
+)
+
+// error messages
+const (
+	ErrUnknownStrategy = "unknown readiness strategy "
+	ErrMissingOption   = "missing readiness option "
+)
+
+//forgetAfter is period after which stable strategy forgets files it hasn't seen
+const forgetAfter = 24 * time.Hour
+
+//FileSystem is remote file system the strategies check
+type FileSystem interface {
+	Stat(p string) (os.FileInfo, error)
+	ReadFile(p string) ([]byte, error)
+}
+
+//Strategy decides whether remote file is completely uploaded and can be downloaded
+type Strategy interface {
+	//Ready returns true if file p described by info can be downloaded
+	Ready(fs FileSystem, p string, info os.FileInfo) (bool, error)
+	//Markers returns files signalling readiness of p, they are removed once p is downloaded
+	Markers(p string) []string
+}
+
+//Walker is implemented by strategies keeping state of one walk of remote
+type Walker interface {
+	//Begin is called before walk of remote starts
+	Begin()
+}
+
+//Begin tells strategy new walk of remote starts
+func Begin(strategy Strategy) {
+	if walker, ok := strategy.(Walker); ok {
+		walker.Begin()
+	}
+}
+
+//Options configures strategy
+type Options struct {
+	//Strategy is one of marker, extension, stable, age, manifest or none. Default is marker
+	Strategy string
+	//Suffix of marker file for marker strategy, i.e. `_0` for <file>_0
+	Suffix string
+	//Extension replacing extension of file for extension strategy, i.e. `.done` for <file>.done
+	Extension string
+	//Polls is number of polls file size and modification time must not change for stable strategy
+	Polls int
+	//MinAge is minimal age of file for age strategy, i.e. `5m`
+	MinAge string
+	//Manifest is name of file listing ready files in the same folder for manifest strategy
+	Manifest string
+}
+
+//New creates strategy by options
+func New(options Options) (Strategy, error) {
+	switch strings.ToLower(options.Strategy) {
+	case "", StrategyMarker:
+		return &MarkerSuffix{Suffix: options.Suffix}, nil
+	case StrategyExtension:
+		if options.Extension == "" {
+			return nil, errors.New(ErrMissingOption + "extension")
+		}
+		return &MarkerExtension{Extension: options.Extension}, nil
+	case StrategyStable:
+		if options.Polls < 1 {
+			return nil, errors.New(ErrMissingOption + "polls")
+		}
+		return &Stable{Polls: options.Polls}, nil
+	case StrategyAge:
+		age, err := time.ParseDuration(options.MinAge)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrMissingOption+"min age")
+		}
+		return &MinAge{Age: age}, nil
+	case StrategyManifest:
+		if options.Manifest == "" {
+			return nil, errors.New(ErrMissingOption + "manifest")
+		}
+		return &Manifest{Name: options.Manifest}, nil
+	case StrategyNone:
+		return &MarkerSuffix{}, nil
+	}
+	return nil, errors.New(ErrUnknownStrategy + options.Strategy)
+}
+
+//Polled returns true if strategy decides readiness by comparing polls of running service, so command running
+//once never sees a file ready
+func Polled(strategy string) bool {
+	return strings.ToLower(strategy) == StrategyStable
+}
+
+//MarkerSuffix requires zero len marker <file><Suffix>, i.e. <file>_0. Empty suffix means every file is ready
+type MarkerSuffix struct {
+	Suffix string
+}
+
+//Ready returns true if marker exists
+func (m *MarkerSuffix) Ready(fs FileSystem, p string, info os.FileInfo) (bool, error) {
+	if m.Suffix == "" {
+		return true, nil
+	}
+	return exists(fs, p+m.Suffix), nil
+}
+
+//Markers returns marker file
+func (m *MarkerSuffix) Markers(p string) []string {
+	if m.Suffix == "" {
+		return nil
+	}
+	return []string{p + m.Suffix}
+}
+
+//MarkerExtension requires marker with replaced extension, i.e. <name>.done for <name>.zip
+type MarkerExtension struct {
+	Extension string
+}
+
+//Ready returns true if marker exists
+func (m *MarkerExtension) Ready(fs FileSystem, p string, info os.FileInfo) (bool, error) {
+	return exists(fs, m.marker(p)), nil
+}
+
+//Markers returns marker file
+func (m *MarkerExtension) Markers(p string) []string {
+	return []string{m.marker(p)}
+}
+
+func (m *MarkerExtension) marker(p string) string {
+	return strings.TrimSuffix(p, path.Ext(p)) + m.Extension
+}
+
+//Stable considers file ready when its size and modification time don't change across Polls consecutive polls.
+//Observations are kept by the instance, so the same instance must be used by all polls
+type Stable struct {
+	Polls int
+
+	mu           sync.Mutex
+	observations map[string]*observation
+}
+
+type observation struct {
+	size     int64
+	modTime  time.Time
+	count    int
+	lastSeen time.Time
+}
+
+//Begin forgets files not seen for forgetAfter
+func (s *Stable) Begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for file, o := range s.observations {
+		if now.Sub(o.lastSeen) > forgetAfter {
+			delete(s.observations, file)
+		}
+	}
+}
+
+//Ready returns true if file didn't change during last Polls polls
+func (s *Stable) Ready(fs FileSystem, p string, info os.FileInfo) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.observations == nil {
+		s.observations = map[string]*observation{}
+	}
+	now := time.Now()
+	o, ok := s.observations[p]
+	if !ok || o.size != info.Size() || !o.modTime.Equal(info.ModTime()) {
+		s.observations[p] = &observation{size: info.Size(), modTime: info.ModTime(), lastSeen: now}
+		return false, nil
+	}
+	o.count++
+	o.lastSeen = now
+	return o.count >= s.Polls, nil
+}
+
+//Markers returns nothing, stable strategy has no markers
+func (s *Stable) Markers(p string) []string {
+	return nil
+}
+
+//MinAge considers file ready when it was not modified for Age
+type MinAge struct {
+	Age time.Duration
+}
+
+//Ready returns true if file is older than Age
+func (m *MinAge) Ready(fs FileSystem, p string, info os.FileInfo) (bool, error) {
+	return time.Since(info.ModTime()) >= m.Age, nil
+}
+
+//Markers returns nothing, age strategy has no markers
+func (m *MinAge) Markers(p string) []string {
+	return nil
+}
+
+//Manifest considers file ready when it is listed in manifest Name in the same folder. Manifest lists one file
+//name per line, `sha256sum` format is accepted as well. Manifest is left on remote, partner maintains it.
+//Manifest of folder is read once per walk
+type Manifest struct {
+	Name string
+
+	mu     sync.Mutex
+	listed map[string]listed
+}
+
+//listed are files listed in manifest of folder
+type listed struct {
+	files map[string]bool
+	err   error
+}
+
+//Begin forgets manifests read by previous walk
+func (m *Manifest) Begin() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listed = nil
+}
+
+//Ready returns true if file is listed in manifest
+func (m *Manifest) Ready(fs FileSystem, p string, info os.FileInfo) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.listed == nil {
+		m.listed = map[string]listed{}
+	}
+	dir := path.Dir(p)
+	manifest, ok := m.listed[dir]
+	if !ok {
+		manifest = m.read(fs, dir)
+		m.listed[dir] = manifest
+	}
+	if manifest.err != nil {
+		return false, errors.Wrapf(manifest.err, "cannot read manifest for %s", p)
+	}
+	return manifest.files[path.Base(p)], nil
+}
+
+//read reads names listed in manifest of dir; missing manifest lists nothing
+func (m *Manifest) read(fs FileSystem, dir string) listed {
+	content, err := fs.ReadFile(path.Join(dir, m.Name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return listed{err: err}
+	}
+	files := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			files[strings.TrimPrefix(fields[len(fields)-1], "*")] = true
+		}
+	}
+	return listed{files: files, err: scanner.Err()}
+}
+
+//Markers returns nothing, manifest is not removed
+func (m *Manifest) Markers(p string) []string {
+	return nil
+}
+
+func exists(fs FileSystem, p string) bool {
+	_, err := fs.Stat(p)
+	return err == nil
+}
@@ -0,0 +1,237 @@
+package readiness
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const tested = "/home/ec2-user/COBA/KV1212_T_EDT_Bonds180808.zip"
+
+//fakeFS is in-memory remote file system
+type fakeFS map[string]*fakeInfo
+
+func (fs fakeFS) Stat(p string) (os.FileInfo, error) {
+	if info, ok := fs[p]; ok {
+		return info, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (fs fakeFS) ReadFile(p string) ([]byte, error) {
+	if info, ok := fs[p]; ok {
+		return info.content, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+type fakeInfo struct {
+	os.FileInfo
+	size    int64
+	modTime time.Time
+	content []byte
+}
+
+func (f *fakeInfo) Size() int64        { return f.size }
+func (f *fakeInfo) ModTime() time.Time { return f.modTime }
+
+func TestMarkerSuffix(t *testing.T) {
+	//arrange
+	strategy, err := New(Options{Strategy: StrategyMarker, Suffix: "_0"})
+	assert.NoError(t, err)
+	fs := fakeFS{tested: &fakeInfo{}}
+
+	//act
+	before, _ := strategy.Ready(fs, tested, fs[tested])
+	fs[tested+"_0"] = &fakeInfo{}
+	after, _ := strategy.Ready(fs, tested, fs[tested])
+
+	//assert
+	assert.False(t, before)
+	assert.True(t, after)
+	assert.Equal(t, []string{tested + "_0"}, strategy.Markers(tested))
+}
+
+func TestEmptyMarkerSuffix(t *testing.T) {
+	//arrange
+	strategy, err := New(Options{})
+	assert.NoError(t, err)
+	fs := fakeFS{tested: &fakeInfo{}}
+
+	//act
+	ready, err := strategy.Ready(fs, tested, fs[tested])
+
+	//assert
+	assert.NoError(t, err)
+	assert.True(t, ready)
+	assert.Empty(t, strategy.Markers(tested), "file itself must never be removed as marker")
+}
+
+func TestMarkerExtension(t *testing.T) {
+	//arrange
+	strategy, err := New(Options{Strategy: StrategyExtension, Extension: ".done"})
+	assert.NoError(t, err)
+	fs := fakeFS{tested: &fakeInfo{}, "/home/ec2-user/COBA/KV1212_T_EDT_Bonds180808.done": &fakeInfo{}}
+
+	//act
+	ready, err := strategy.Ready(fs, tested, fs[tested])
+
+	//assert
+	assert.NoError(t, err)
+	assert.True(t, ready)
+	assert.Equal(t, []string{"/home/ec2-user/COBA/KV1212_T_EDT_Bonds180808.done"}, strategy.Markers(tested))
+}
+
+func TestStable(t *testing.T) {
+	//arrange
+	strategy, err := New(Options{Strategy: StrategyStable, Polls: 2})
+	assert.NoError(t, err)
+	modTime := time.Now()
+	growing := &fakeInfo{size: 10, modTime: modTime}
+	fs := fakeFS{tested: growing}
+
+	//act
+	first, _ := strategy.Ready(fs, tested, growing)
+	growing.size = 20
+	second, _ := strategy.Ready(fs, tested, growing)
+	third, _ := strategy.Ready(fs, tested, growing)
+	fourth, _ := strategy.Ready(fs, tested, growing)
+
+	//assert
+	assert.False(t, first)
+	assert.False(t, second, "size changed")
+	assert.False(t, third)
+	assert.True(t, fourth)
+}
+
+func TestStableObservationsAreNotShared(t *testing.T) {
+	//arrange
+	first, err := New(Options{Strategy: StrategyStable, Polls: 1})
+	assert.NoError(t, err)
+	second, err := New(Options{Strategy: StrategyStable, Polls: 1})
+	assert.NoError(t, err)
+	info := &fakeInfo{size: 10, modTime: time.Now()}
+	fs := fakeFS{tested: info}
+
+	//act
+	first.Ready(fs, tested, info)
+	ready, _ := second.Ready(fs, tested, info)
+
+	//assert
+	assert.False(t, ready, "second strategy has not seen the file yet")
+}
+
+func TestMinAge(t *testing.T) {
+	//arrange
+	strategy, err := New(Options{Strategy: StrategyAge, MinAge: "5m"})
+	assert.NoError(t, err)
+	fresh := &fakeInfo{modTime: time.Now()}
+	old := &fakeInfo{modTime: time.Now().Add(-10 * time.Minute)}
+
+	//act
+	freshReady, _ := strategy.Ready(fakeFS{}, tested, fresh)
+	oldReady, _ := strategy.Ready(fakeFS{}, tested, old)
+
+	//assert
+	assert.False(t, freshReady)
+	assert.True(t, oldReady)
+}
+
+func TestManifest(t *testing.T) {
+	//arrange
+	strategy, err := New(Options{Strategy: StrategyManifest, Manifest: "batch.manifest"})
+	assert.NoError(t, err)
+	fs := fakeFS{
+		tested:                               &fakeInfo{},
+		"/home/ec2-user/COBA/batch.manifest": &fakeInfo{content: []byte("KV1212_T_EDT_Warrants180808.zip\nKV1212_T_EDT_Bonds180808.zip\n")},
+	}
+
+	//act
+	listed, err := strategy.Ready(fs, tested, fs[tested])
+	notListed, _ := strategy.Ready(fs, "/home/ec2-user/COBA/KV0011_T_EDT_Warrant01.zip", &fakeInfo{})
+	noManifest, _ := strategy.Ready(fs, "/home/ec2-user/BRCLS/KV0011_T_EDT_Warrant01.zip", &fakeInfo{})
+
+	//assert
+	assert.NoError(t, err)
+	assert.True(t, listed)
+	assert.False(t, notListed)
+	assert.False(t, noManifest)
+}
+
+func TestManifestIsReadOncePerWalk(t *testing.T) {
+	//arrange
+	strategy, err := New(Options{Strategy: StrategyManifest, Manifest: "batch.manifest"})
+	assert.NoError(t, err)
+	manifest := "/home/ec2-user/COBA/batch.manifest"
+	fs := &countingFS{fakeFS: fakeFS{
+		tested:   &fakeInfo{},
+		manifest: &fakeInfo{content: []byte("KV1212_T_EDT_Warrants180808.zip\n")},
+	}}
+
+	//act
+	Begin(strategy)
+	strategy.Ready(fs, tested, &fakeInfo{})
+	before, _ := strategy.Ready(fs, tested, &fakeInfo{})
+	fs.fakeFS[manifest].content = []byte("KV1212_T_EDT_Bonds180808.zip\n")
+	cached, _ := strategy.Ready(fs, tested, &fakeInfo{})
+	Begin(strategy)
+	after, _ := strategy.Ready(fs, tested, &fakeInfo{})
+
+	//assert
+	assert.False(t, before)
+	assert.False(t, cached, "manifest is read once per walk")
+	assert.True(t, after)
+	assert.Equal(t, 2, fs.reads)
+}
+
+func TestStableForgetsOldObservations(t *testing.T) {
+	//arrange
+	strategy := &Stable{Polls: 1}
+	info := &fakeInfo{size: 10, modTime: time.Now()}
+	fs := fakeFS{tested: info}
+	strategy.Ready(fs, tested, info)
+	strategy.observations[tested].lastSeen = time.Now().Add(-2 * forgetAfter)
+
+	//act
+	Begin(strategy)
+	ready, _ := strategy.Ready(fs, tested, info)
+
+	//assert
+	assert.False(t, ready, "forgotten file is seen for the first time")
+}
+
+//countingFS counts reads of files
+type countingFS struct {
+	fakeFS
+	reads int
+}
+
+func (fs *countingFS) ReadFile(p string) ([]byte, error) {
+	fs.reads++
+	return fs.fakeFS.ReadFile(p)
+}
+
+func TestPolled(t *testing.T) {
+	for strategy, polled := range map[string]bool{StrategyStable: true, "Stable": true, StrategyMarker: false, "": false,
+		StrategyAge: false, StrategyManifest: false} {
+		//arrange
+		//act
+		//assert
+		assert.Equal(t, polled, Polled(strategy), strategy)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	//arrange
+	//act
+	_, errUnknown := New(Options{Strategy: "magic"})
+	_, errPolls := New(Options{Strategy: StrategyStable})
+	_, errAge := New(Options{Strategy: StrategyAge, MinAge: "yesterday"})
+
+	//assert
+	assert.Error(t, errUnknown)
+	assert.Error(t, errPolls)
+	assert.Error(t, errAge)
+}
@@ -0,0 +1,298 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Deutsche-Boerse/edt-sftp/constants"
+	"github.com/pkg/errors"
+)
+
+// acknowledge types
+const (
+	TypeDefault  = ""
+	TypeJSON     = "json"
+	TypeXML      = "xml"
+	TypeCSV      = "csv"
+	TypeTemplate = "template"
+)
+
+// csv columns
+const (
+	ColumnFile      = "file"
+	ColumnPartner   = "partner"
+	ColumnSize      = "size"
+	ColumnHash      = "sha256"
+	ColumnStatus    = "status"
+	ColumnTimestamp = "timestamp"
+	ColumnCode      = "code"
+	ColumnReason    = "reason"
+	ColumnReceipt   = "receipt"
+	ColumnAccepted  = "accepted"
+	ColumnMessages  = "messages"
+)
+
+// error messages
+const (
+	ErrUnknownType     = "unknown acknowledge type "
+	ErrUnknownColumn   = "unknown acknowledge column "
+	ErrInvalidTemplate = "invalid acknowledge template "
+	ErrInvalidTimezone = "invalid acknowledge timezone "
+	ErrInvalidSep      = "acknowledge separator must be single character other than quote or line break "
+	ErrInvalidName     = "acknowledge name must not contain path separator "
+)
+
+const defaultTimeFormat = "20060102T150405"
+
+//Format describes acknowledge file of partner
+type Format struct {
+	//Type is one of json, xml, csv or template. Empty type produces `<file>;<timestamp>`, followed by status
+	//unless it is ok and by code and reason of rejected delivery. Receipt of gateway is available to other types only
+	Type string
+	//Name is template of acknowledge file name, default is `{{.FileName}}.response`
+	Name string
+	//Template is content template for template type, i.e. `{{.FileName}}|{{.Status}}|{{.Time}}`
+	Template string
+	//Columns of csv type, default is file, timestamp and status
+	Columns []string
+	//Separator of csv type, default is `;`
+	Separator string
+	//Header writes column names as the first csv line
+	Header bool
+	//TimeFormat is Go layout of timestamp, default is 20060102T150405
+	TimeFormat string
+	//Timezone is IANA name of timezone of timestamp, i.e. Europe/Berlin. Default is local timezone
+	Timezone string
+}
+
+//Fields are values available for acknowledge name and content
+type Fields struct {
+	//FileName is name of delivered file
+	FileName string
+	//BaseName is FileName without extension
+	BaseName string
+	Partner  string
+	Size     int64
+	Hash     string
+	Status   string
+	//Code and Reason describe why delivery was rejected, they are empty for accepted deliveries
+	Code   string
+	Reason string
+	//ReceiptID, Accepted and Messages come from receipt of gateway
+	ReceiptID string
+	Accepted  []string
+	Messages  []string
+	Timestamp time.Time
+	//Time is Timestamp formatted by TimeFormat
+	Time string
+}
+
+//xmlAcknowledge is content of xml acknowledge
+type xmlAcknowledge struct {
+	XMLName   xml.Name `xml:"acknowledge"`
+	File      string   `xml:"file"`
+	Partner   string   `xml:"partner,omitempty"`
+	Size      int64    `xml:"size"`
+	Hash      string   `xml:"sha256,omitempty"`
+	Status    string   `xml:"status"`
+	Code      string   `xml:"code,omitempty"`
+	Reason    string   `xml:"reason,omitempty"`
+	ReceiptID string   `xml:"receiptId,omitempty"`
+	Accepted  *xmlList `xml:"accepted"`
+	Messages  *xmlList `xml:"messages"`
+	Timestamp string   `xml:"timestamp"`
+}
+
+//xmlList wraps list elements, nil list is omitted
+type xmlList struct {
+	Files    []string `xml:"file,omitempty"`
+	Messages []string `xml:"message,omitempty"`
+}
+
+//jsonAcknowledge is content of json acknowledge
+type jsonAcknowledge struct {
+	File      string   `json:"file"`
+	Partner   string   `json:"partner,omitempty"`
+	Size      int64    `json:"size"`
+	Hash      string   `json:"sha256,omitempty"`
+	Status    string   `json:"status"`
+	Code      string   `json:"code,omitempty"`
+	Reason    string   `json:"reason,omitempty"`
+	ReceiptID string   `json:"receiptId,omitempty"`
+	Accepted  []string `json:"accepted,omitempty"`
+	Messages  []string `json:"messages,omitempty"`
+	Timestamp string   `json:"timestamp"`
+}
+
+//Generator creates acknowledges of single format
+type Generator struct {
+	format   Format
+	name     *template.Template
+	body     *template.Template
+	location *time.Location
+	now      func() time.Time
+}
+
+//NewGenerator validates format and creates generator
+func NewGenerator(format Format) (*Generator, error) {
+	g := &Generator{format: format, location: time.Local, now: time.Now}
+	if g.format.TimeFormat == "" {
+		g.format.TimeFormat = defaultTimeFormat
+	}
+	if g.format.Separator == "" {
+		g.format.Separator = ";"
+	}
+	if len(g.format.Columns) == 0 {
+		g.format.Columns = []string{ColumnFile, ColumnTimestamp, ColumnStatus}
+	}
+	if format.Timezone != "" {
+		location, err := time.LoadLocation(format.Timezone)
+		if err != nil {
+			return nil, errors.Wrap(err, ErrInvalidTimezone+format.Timezone)
+		}
+		g.location = location
+	}
+	name := format.Name
+	if name == "" {
+		name = "{{.FileName}}" + constants.RESPONSE
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return nil, errors.New(ErrInvalidName + name)
+	}
+	var err error
+	if g.name, err = template.New("name").Parse(name); err != nil {
+		return nil, errors.Wrap(err, ErrInvalidTemplate+name)
+	}
+	switch strings.ToLower(format.Type) {
+	case TypeDefault, TypeJSON, TypeXML:
+	case TypeCSV:
+		if utf8.RuneCountInString(g.format.Separator) != 1 || strings.ContainsAny(g.format.Separator, "\"\r\n") {
+			return nil, errors.New(ErrInvalidSep + strconv.Quote(g.format.Separator))
+		}
+		for _, column := range g.format.Columns {
+			if _, err = (Fields{}).column(column); err != nil {
+				return nil, err
+			}
+		}
+	case TypeTemplate:
+		if g.body, err = template.New("content").Parse(format.Template); err != nil {
+			return nil, errors.Wrap(err, ErrInvalidTemplate+format.Template)
+		}
+	default:
+		return nil, errors.New(ErrUnknownType + format.Type)
+	}
+	return g, nil
+}
+
+//Acknowledge creates acknowledge of delivery described by fields. FileName is reduced to base name,
+//BaseName, Timestamp and Time are filled by generator
+func (g *Generator) Acknowledge(fields Fields) (Acknowledge, error) {
+	fields.FileName = filepath.Base(fields.FileName)
+	fields.BaseName = strings.TrimSuffix(fields.FileName, filepath.Ext(fields.FileName))
+	fields.Timestamp = g.now().In(g.location)
+	fields.Time = fields.Timestamp.Format(g.format.TimeFormat)
+
+	var name bytes.Buffer
+	if err := g.name.Execute(&name, fields); err != nil {
+		return Acknowledge{}, errors.Wrap(err, "cannot create acknowledge name")
+	}
+	//fields such as Reason may render separator, acknowledge must stay next to delivered file
+	if strings.ContainsAny(name.String(), `/\`) {
+		return Acknowledge{}, errors.New(ErrInvalidName + name.String())
+	}
+	content, err := g.content(fields)
+	if err != nil {
+		return Acknowledge{}, errors.Wrapf(err, "cannot create acknowledge %s", name.String())
+	}
+	return Acknowledge{Name: name.String(), Content: content}, nil
+}
+
+func (g *Generator) content(fields Fields) ([]byte, error) {
+	switch strings.ToLower(g.format.Type) {
+	case TypeJSON:
+		return json.Marshal(jsonAcknowledge{File: fields.FileName, Partner: fields.Partner, Size: fields.Size,
+			Hash: fields.Hash, Status: fields.Status, Code: fields.Code, Reason: fields.Reason, ReceiptID: fields.ReceiptID,
+			Accepted: fields.Accepted, Messages: fields.Messages, Timestamp: fields.Time})
+	case TypeXML:
+		ack := xmlAcknowledge{File: fields.FileName, Partner: fields.Partner, Size: fields.Size, Hash: fields.Hash,
+			Status: fields.Status, Code: fields.Code, Reason: fields.Reason, ReceiptID: fields.ReceiptID, Timestamp: fields.Time}
+		if len(fields.Accepted) > 0 {
+			ack.Accepted = &xmlList{Files: fields.Accepted}
+		}
+		if len(fields.Messages) > 0 {
+			ack.Messages = &xmlList{Messages: fields.Messages}
+		}
+		content, err := xml.Marshal(ack)
+		return append([]byte(xml.Header), content...), err
+	case TypeCSV:
+		return g.csv(fields)
+	case TypeTemplate:
+		var content bytes.Buffer
+		err := g.body.Execute(&content, fields)
+		return content.Bytes(), err
+	}
+	content := fields.FileName + ";" + fields.Time
+	if fields.Status != "" && fields.Status != StatusOK {
+		content += ";" + fields.Status
+	}
+	if fields.Code != "" {
+		content += ";" + fields.Code + ";" + fields.Reason
+	}
+	return []byte(content), nil
+}
+
+func (g *Generator) csv(fields Fields) ([]byte, error) {
+	var content bytes.Buffer
+	w := csv.NewWriter(&content)
+	w.Comma = []rune(g.format.Separator)[0]
+	if g.format.Header {
+		w.Write(g.format.Columns)
+	}
+	var record []string
+	for _, column := range g.format.Columns {
+		value, err := fields.column(column)
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, value)
+	}
+	w.Write(record)
+	w.Flush()
+	return content.Bytes(), w.Error()
+}
+
+func (f Fields) column(column string) (string, error) {
+	switch strings.ToLower(column) {
+	case ColumnFile:
+		return f.FileName, nil
+	case ColumnPartner:
+		return f.Partner, nil
+	case ColumnSize:
+		return strconv.FormatInt(f.Size, 10), nil
+	case ColumnHash:
+		return f.Hash, nil
+	case ColumnStatus:
+		return f.Status, nil
+	case ColumnTimestamp:
+		return f.Time, nil
+	case ColumnCode:
+		return f.Code, nil
+	case ColumnReason:
+		return f.Reason, nil
+	case ColumnReceipt:
+		return f.ReceiptID, nil
+	case ColumnAccepted:
+		return strings.Join(f.Accepted, "|"), nil
+	case ColumnMessages:
+		return strings.Join(f.Messages, "|"), nil
+	}
+	return "", errors.New(ErrUnknownColumn + column)
+}
@@ -0,0 +1,168 @@
+package response
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fields = Fields{
+	FileName: "/opt/edt/sftp/KV0011_T_EDT_Warrant01.zip-123/KV0011_T_EDT_Warrant01.zip",
+	Partner:  "COBA",
+	Size:     1024,
+	Hash:     "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	Status:   StatusOK,
+}
+
+func newTestGenerator(t *testing.T, format Format) *Generator {
+	g, err := NewGenerator(format)
+	assert.NoError(t, err)
+	g.now = func() time.Time { return time.Date(2018, 8, 8, 12, 53, 32, 0, time.UTC) }
+	return g
+}
+
+func TestDefaultFormat(t *testing.T) {
+	//arrange
+	g := newTestGenerator(t, Format{Timezone: "UTC"})
+	duplicate := fields
+	duplicate.Status = StatusDuplicate
+
+	//act
+	ack, err := g.Acknowledge(fields)
+	dupAck, dupErr := g.Acknowledge(duplicate)
+
+	//assert
+	assert.NoError(t, err)
+	assert.NoError(t, dupErr)
+	assert.Equal(t, "KV0011_T_EDT_Warrant01.zip.response", ack.Name)
+	assert.Equal(t, "KV0011_T_EDT_Warrant01.zip;20180808T125332", string(ack.Content))
+	assert.Equal(t, "KV0011_T_EDT_Warrant01.zip;20180808T125332;duplicate", string(dupAck.Content))
+}
+
+func TestJSONFormatWithTimezone(t *testing.T) {
+	//arrange
+	g := newTestGenerator(t, Format{Type: TypeJSON, Name: "{{.BaseName}}.ack.json", TimeFormat: time.RFC3339, Timezone: "Europe/Berlin"})
+
+	//act
+	ack, err := g.Acknowledge(fields)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, "KV0011_T_EDT_Warrant01.ack.json", ack.Name)
+	assert.JSONEq(t, `{"file":"KV0011_T_EDT_Warrant01.zip","partner":"COBA","size":1024,
+		"sha256":"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08","status":"ok",
+		"timestamp":"2018-08-08T14:53:32+02:00"}`, string(ack.Content))
+}
+
+func TestXMLFormat(t *testing.T) {
+	//arrange
+	g := newTestGenerator(t, Format{Type: TypeXML, Name: "ACK_{{.BaseName}}_{{.Time}}.xml", Timezone: "UTC"})
+
+	//act
+	ack, err := g.Acknowledge(fields)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, "ACK_KV0011_T_EDT_Warrant01_20180808T125332.xml", ack.Name)
+	assert.Contains(t, string(ack.Content), "<acknowledge><file>KV0011_T_EDT_Warrant01.zip</file><partner>COBA</partner>"+
+		"<size>1024</size><sha256>9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08</sha256>"+
+		"<status>ok</status><timestamp>20180808T125332</timestamp></acknowledge>")
+}
+
+func TestCSVFormat(t *testing.T) {
+	//arrange
+	g := newTestGenerator(t, Format{Type: TypeCSV, Columns: []string{"file", "size", "status"}, Separator: ",", Header: true})
+
+	//act
+	ack, err := g.Acknowledge(fields)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, "file,size,status\nKV0011_T_EDT_Warrant01.zip,1024,ok\n", string(ack.Content))
+}
+
+func TestTemplateFormat(t *testing.T) {
+	//arrange
+	g := newTestGenerator(t, Format{Type: TypeTemplate, Template: "{{.Partner}}|{{.FileName}}|{{.Status}}|{{.Time}}", Timezone: "UTC"})
+
+	//act
+	ack, err := g.Acknowledge(fields)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, "COBA|KV0011_T_EDT_Warrant01.zip|ok|20180808T125332", string(ack.Content))
+}
+
+func TestRejectedContainsCodeAndReason(t *testing.T) {
+	//arrange
+	g := newTestGenerator(t, Format{Name: "{{.FileName}}.nack", Timezone: "UTC"})
+	rejected := fields
+	rejected.Status = StatusRejected
+	rejected.Code = "ZIP_PASSWORD_INVALID"
+	rejected.Reason = "zip: invalid password"
+
+	//act
+	ack, err := g.Acknowledge(rejected)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, "KV0011_T_EDT_Warrant01.zip.nack", ack.Name)
+	assert.Equal(t, "KV0011_T_EDT_Warrant01.zip;20180808T125332;rejected;ZIP_PASSWORD_INVALID;zip: invalid password", string(ack.Content))
+}
+
+func TestReceiptIsIncluded(t *testing.T) {
+	//arrange
+	plain := newTestGenerator(t, Format{Timezone: "UTC"})
+	xmlGenerator := newTestGenerator(t, Format{Type: TypeXML, Timezone: "UTC"})
+	accepted := fields
+	accepted.ReceiptID = "R-2018-0001"
+	accepted.Accepted = []string{"PPCZ01_160101-145332.xml", "TCCZ02_180808-145332.pdf"}
+	accepted.Messages = []string{"TCCZ02_180808-145332.pdf: no ISIN"}
+
+	//act
+	ack, err := plain.Acknowledge(accepted)
+	xmlAck, xmlErr := xmlGenerator.Acknowledge(accepted)
+
+	//assert
+	assert.NoError(t, err)
+	assert.NoError(t, xmlErr)
+	assert.Equal(t, "KV0011_T_EDT_Warrant01.zip;20180808T125332", string(ack.Content))
+	assert.Contains(t, string(xmlAck.Content), "<receiptId>R-2018-0001</receiptId><accepted><file>PPCZ01_160101-145332.xml</file>"+
+		"<file>TCCZ02_180808-145332.pdf</file></accepted><messages><message>TCCZ02_180808-145332.pdf: no ISIN</message></messages>")
+}
+
+func TestNameRenderingPathSeparator(t *testing.T) {
+	//arrange
+	g := newTestGenerator(t, Format{Name: "{{.Reason}}.nack"})
+	rejected := fields
+	rejected.Reason = "../../etc/passwd"
+
+	//act
+	_, err := g.Acknowledge(rejected)
+
+	//assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ErrInvalidName)
+}
+
+func TestInvalidFormats(t *testing.T) {
+	for _, format := range []Format{
+		{Type: "yaml"},
+		{Type: TypeCSV, Columns: []string{"file", "receipt_id"}},
+		{Type: TypeTemplate, Template: "{{.FileName"},
+		{Name: "{{.FileName"},
+		{Timezone: "Mars/Olympus"},
+		{Type: TypeCSV, Separator: "||"},
+		{Type: TypeCSV, Separator: "\""},
+		{Name: "../{{.FileName}}.response"},
+		{Name: `acks\{{.FileName}}.response`},
+	} {
+		//arrange
+		//act
+		_, err := NewGenerator(format)
+
+		//assert
+		assert.Error(t, err, format)
+	}
+}
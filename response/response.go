@@ -6,6 +6,13 @@ import (
 	"time"
 )
 
+// acknowledge statuses
+const (
+	StatusOK        = "ok"
+	StatusDuplicate = "duplicate"
+	StatusRejected  = "rejected"
+)
+
 type Acknowledge struct {
 	Name    string
 	Content []byte
@@ -0,0 +1,155 @@
+package response
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Deutsche-Boerse/edt-sftp/constants"
+	"github.com/Deutsche-Boerse/edt-sftp/pgp"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/pkg/errors"
+)
+
+// signature types
+const (
+	SignatureNone    = ""
+	SignatureEd25519 = "ed25519"
+	SignatureOpenPGP = "openpgp"
+)
+
+// error messages
+const (
+	ErrUnknownSignature = "unknown acknowledge signature "
+	ErrInvalidKey       = "invalid ed25519 key "
+	ErrSignature        = "acknowledge signature verification failed "
+)
+
+//Signer signs acknowledges. Signature is written next to acknowledge as <acknowledge><Extension>
+type Signer interface {
+	Sign(content []byte) ([]byte, error)
+	Extension() string
+}
+
+//NewSigner creates signer of type ed25519 or openpgp with private key from keyFile. Passphrase decrypts
+//OpenPGP key. Nil signer is returned for empty type
+func NewSigner(signature string, keyFile string, passphrase []byte) (Signer, error) {
+	switch strings.ToLower(signature) {
+	case SignatureNone:
+		return nil, nil
+	case SignatureEd25519:
+		key, err := ReadEd25519PrivateKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &Ed25519Signer{Key: key}, nil
+	case SignatureOpenPGP:
+		entities, err := pgp.ReadPrivateKey(keyFile, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return &OpenPGPSigner{Entity: entities[0]}, nil
+	}
+	return nil, errors.New(ErrUnknownSignature + signature)
+}
+
+//SignAcknowledge returns signature file of acknowledge
+func SignAcknowledge(ack Acknowledge, signer Signer) (Acknowledge, error) {
+	signature, err := signer.Sign(ack.Content)
+	if err != nil {
+		return Acknowledge{}, errors.Wrapf(err, "cannot sign %s", ack.Name)
+	}
+	return Acknowledge{Name: ack.Name + signer.Extension(), Content: signature}, nil
+}
+
+//Ed25519Signer writes base64 encoded Ed25519 signature
+type Ed25519Signer struct {
+	Key ed25519.PrivateKey
+}
+
+//Sign returns base64 encoded signature of content
+func (s *Ed25519Signer) Sign(content []byte) ([]byte, error) {
+	signature := ed25519.Sign(s.Key, content)
+	return []byte(base64.StdEncoding.EncodeToString(signature) + "\n"), nil
+}
+
+//Extension of signature file
+func (s *Ed25519Signer) Extension() string {
+	return constants.SIG
+}
+
+//OpenPGPSigner writes armored detached OpenPGP signature
+type OpenPGPSigner struct {
+	Entity *openpgp.Entity
+}
+
+//Sign returns armored detached signature of content
+func (s *OpenPGPSigner) Sign(content []byte) ([]byte, error) {
+	return pgp.SignDetached(content, s.Entity)
+}
+
+//Extension of signature file
+func (s *OpenPGPSigner) Extension() string {
+	return constants.ASC
+}
+
+//VerifyEd25519 verifies base64 encoded Ed25519 signature of acknowledge content
+func VerifyEd25519(content []byte, signature []byte, key ed25519.PublicKey) error {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return errors.Wrap(err, ErrSignature)
+	}
+	if !ed25519.Verify(key, content, decoded) {
+		return errors.New(ErrSignature)
+	}
+	return nil
+}
+
+//VerifyOpenPGP verifies detached OpenPGP signature of acknowledge content against keyring
+func VerifyOpenPGP(content []byte, signature []byte, keyring openpgp.EntityList) error {
+	if _, err := pgp.VerifyDetachedContent(content, signature, keyring); err != nil {
+		return errors.Wrap(err, ErrSignature)
+	}
+	return nil
+}
+
+//ReadEd25519PrivateKey reads base64 encoded 32 byte seed or 64 byte private key
+func ReadEd25519PrivateKey(file string) (ed25519.PrivateKey, error) {
+	key, err := readBase64(file)
+	if err != nil {
+		return nil, err
+	}
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	}
+	return nil, errors.New(ErrInvalidKey + file)
+}
+
+//ReadEd25519PublicKey reads base64 encoded 32 byte public key
+func ReadEd25519PublicKey(file string) (ed25519.PublicKey, error) {
+	key, err := readBase64(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New(ErrInvalidKey + file)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func readBase64(file string) ([]byte, error) {
+	buffer, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read key %s", file)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(buffer)))
+	if err != nil {
+		return nil, errors.Wrap(err, ErrInvalidKey+file)
+	}
+	return key, nil
+}
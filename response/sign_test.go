@@ -0,0 +1,81 @@
+package response
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+)
+
+var ack = Acknowledge{Name: "KV0011_T_EDT_Warrant01.zip.response", Content: []byte("KV0011_T_EDT_Warrant01.zip;20180808T125332")}
+
+func TestEd25519SignatureRoundTrip(t *testing.T) {
+	//arrange
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	privateFile := filepath.Join(testData.OutPath, "ack.key")
+	publicFile := filepath.Join(testData.OutPath, "ack.pub")
+	assert.NoError(t, ioutil.WriteFile(privateFile, []byte(base64.StdEncoding.EncodeToString(private.Seed())+"\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(publicFile, []byte(base64.StdEncoding.EncodeToString(public)), 0644))
+	signer, err := NewSigner(SignatureEd25519, privateFile, nil)
+	assert.NoError(t, err)
+
+	//act
+	signature, err := SignAcknowledge(ack, signer)
+	key, keyErr := ReadEd25519PublicKey(publicFile)
+
+	//assert
+	assert.NoError(t, err)
+	assert.NoError(t, keyErr)
+	assert.Equal(t, "KV0011_T_EDT_Warrant01.zip.response.sig", signature.Name)
+	assert.NoError(t, VerifyEd25519(ack.Content, signature.Content, key))
+	assert.Error(t, VerifyEd25519([]byte("KV0011_T_EDT_Warrant01.zip;20180808T125333"), signature.Content, key))
+}
+
+func TestOpenPGPSignatureRoundTrip(t *testing.T) {
+	//arrange
+	entity, err := openpgp.NewEntity("edt", "test", "edt@example.com", nil)
+	assert.NoError(t, err)
+	for _, identity := range entity.Identities {
+		//RIPEMD160 is used when no preference is set and it is not compiled in
+		identity.SelfSignature.PreferredHash = []uint8{8}
+	}
+	keyFile := filepath.Join(testData.OutPath, "ack.asc")
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PrivateKeyType, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, entity.SerializePrivate(w, nil))
+	assert.NoError(t, w.Close())
+	assert.NoError(t, ioutil.WriteFile(keyFile, armored.Bytes(), 0600))
+	signer, err := NewSigner(SignatureOpenPGP, keyFile, nil)
+	assert.NoError(t, err)
+
+	//act
+	signature, err := SignAcknowledge(ack, signer)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, "KV0011_T_EDT_Warrant01.zip.response.asc", signature.Name)
+	assert.NoError(t, VerifyOpenPGP(ack.Content, signature.Content, openpgp.EntityList{entity}))
+	assert.Error(t, VerifyOpenPGP([]byte("tampered"), signature.Content, openpgp.EntityList{entity}))
+}
+
+func TestNoSigner(t *testing.T) {
+	//arrange
+	//act
+	signer, err := NewSigner(SignatureNone, "", nil)
+	_, unknownErr := NewSigner("rsa", "", nil)
+	_, missingErr := NewSigner(SignatureEd25519, filepath.Join(testData.OutPath, "missing.key"), nil)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Nil(t, signer)
+	assert.Error(t, unknownErr)
+	assert.Error(t, missingErr)
+}
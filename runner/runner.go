@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// overlap policies, decide what happens when job is triggered while previous run is still in progress
+const (
+	//PolicySkip drops the new run
+	PolicySkip = "skip"
+	//PolicyQueue keeps one run waiting for the current one, other runs are dropped
+	PolicyQueue = "queue"
+	//PolicyWait blocks every new run until the previous ones finish
+	PolicyWait = "wait"
+)
+
+// error messages
+const (
+	ErrUnknownPolicy = "unknown overlap policy "
+)
+
+//ErrSkipped is returned by Trigger when run was dropped by overlap policy
+var ErrSkipped = errors.New("previous run is still in progress")
+
+//ErrStopped is returned by Trigger once runner was stopped
+var ErrStopped = errors.New("runner is stopped")
+
+type idKey struct{}
+
+//Job is function executed by runner. Job should stop as soon as ctx is done
+type Job func(ctx context.Context) error
+
+//State is snapshot of runner
+type State struct {
+	Running bool
+	Queued  bool
+	//RunID and Started describe current run if Running, the last run otherwise
+	RunID   string
+	Started time.Time
+	//LastError is error of the last finished run
+	LastError error
+	Finished  time.Time
+}
+
+//Runner executes job so that runs never overlap. It implements cron.Job
+type Runner struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	policy string
+	job    Job
+	//slot is held by running job
+	slot chan struct{}
+	//stopped is closed by Stop, runs is count of triggered runs
+	stopped chan struct{}
+	runs    sync.WaitGroup
+
+	mu    sync.Mutex
+	seq   uint64
+	state State
+}
+
+//New creates runner of job; runs started by cron get ctx. Empty policy means skip
+func New(ctx context.Context, policy string, job Job) (*Runner, error) {
+	switch policy {
+	case "":
+		policy = PolicySkip
+	case PolicySkip, PolicyQueue, PolicyWait:
+	default:
+		return nil, errors.New(ErrUnknownPolicy + policy)
+	}
+	r := &Runner{policy: policy, job: job, slot: make(chan struct{}, 1), stopped: make(chan struct{})}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	return r, nil
+}
+
+//Run triggers job from cron; skipped runs and errors are logged
+func (r *Runner) Run() {
+	id, err := r.Trigger(r.ctx)
+	if err == ErrSkipped || err == ErrStopped {
+		log.Info().Msgf("run skipped, %s", err)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("run %s failed", id)
+	}
+}
+
+//Trigger runs job according to overlap policy and returns ID of the run. ErrSkipped is returned if the run
+//was dropped, ctx error if ctx was done while waiting for previous run. Job is cancelled when either ctx
+//or context of runner is done
+func (r *Runner) Trigger(ctx context.Context) (string, error) {
+	return r.Do(ctx, r.job)
+}
+
+//Do runs other job, i.e. manual operation, as run of runner so it never overlaps with scheduled runs
+func (r *Runner) Do(ctx context.Context, job Job) (string, error) {
+	r.mu.Lock()
+	select {
+	case <-r.stopped:
+		r.mu.Unlock()
+		return "", ErrStopped
+	default:
+	}
+	r.runs.Add(1)
+	r.mu.Unlock()
+	defer r.runs.Done()
+
+	if err := r.acquire(ctx); err != nil {
+		return "", err
+	}
+	defer func() { <-r.slot }()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-r.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	id := r.begin()
+	log.Info().Msgf("run %s started", id)
+	err := job(context.WithValue(ctx, idKey{}, id))
+	r.end(err)
+	log.Info().Msgf("run %s finished", id)
+	return id, err
+}
+
+//Stop stops accepting new runs, runs waiting for the current one are dropped. Stop waits until the current
+//run finishes; if ctx is done first, the run is cancelled, Stop waits until it returns and ctx error is returned
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	select {
+	case <-r.stopped:
+	default:
+		close(r.stopped)
+	}
+	r.mu.Unlock()
+	finished := make(chan struct{})
+	go func() {
+		r.runs.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+//State returns snapshot of runner
+func (r *Runner) State() State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+//ID returns ID of the run executing ctx, empty string outside of run
+func ID(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+func (r *Runner) acquire(ctx context.Context) error {
+	select {
+	case r.slot <- struct{}{}:
+		return nil
+	default:
+	}
+	switch r.policy {
+	case PolicySkip:
+		return ErrSkipped
+	case PolicyQueue:
+		r.mu.Lock()
+		if r.state.Queued {
+			r.mu.Unlock()
+			return ErrSkipped
+		}
+		r.state.Queued = true
+		r.mu.Unlock()
+		defer func() {
+			r.mu.Lock()
+			r.state.Queued = false
+			r.mu.Unlock()
+		}()
+	}
+	select {
+	case r.slot <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.stopped:
+		return ErrStopped
+	}
+	//stopping runner may win the race with releasing the slot
+	select {
+	case <-r.stopped:
+		<-r.slot
+		return ErrStopped
+	default:
+		return nil
+	}
+}
+
+func (r *Runner) begin() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	r.state.Running = true
+	r.state.Started = time.Now()
+	r.state.RunID = fmt.Sprintf("%s-%d", r.state.Started.UTC().Format("20060102T150405"), r.seq)
+	return r.state.RunID
+}
+
+func (r *Runner) end(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state.Running = false
+	r.state.LastError = err
+	r.state.Finished = time.Now()
+}
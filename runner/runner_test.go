@@ -0,0 +1,224 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//blocking returns job which blocks until release is closed and counts started runs
+func blocking(started *int32, release chan struct{}) Job {
+	return func(ctx context.Context) error {
+		atomic.AddInt32(started, 1)
+		<-release
+		return nil
+	}
+}
+
+//trigger starts run in background and reports its error into errs
+func trigger(r *Runner, wg *sync.WaitGroup, errs chan error) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := r.Trigger(context.Background())
+		errs <- err
+	}()
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSkipPolicy(t *testing.T) {
+	//arrange
+	var started int32
+	release := make(chan struct{})
+	r, err := New(context.Background(), PolicySkip, blocking(&started, release))
+	assert.NoError(t, err)
+	wg := &sync.WaitGroup{}
+	errs := make(chan error, 2)
+	trigger(r, wg, errs)
+	waitFor(t, func() bool { return atomic.LoadInt32(&started) == 1 })
+
+	//act
+	_, skipErr := r.Trigger(context.Background())
+	close(release)
+	wg.Wait()
+
+	//assert
+	assert.Equal(t, ErrSkipped, skipErr)
+	assert.NoError(t, <-errs)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&started))
+}
+
+func TestQueuePolicy(t *testing.T) {
+	//arrange
+	var started int32
+	release := make(chan struct{})
+	r, err := New(context.Background(), PolicyQueue, blocking(&started, release))
+	assert.NoError(t, err)
+	wg := &sync.WaitGroup{}
+	errs := make(chan error, 3)
+	trigger(r, wg, errs)
+	waitFor(t, func() bool { return r.State().Running })
+	trigger(r, wg, errs)
+	waitFor(t, func() bool { return r.State().Queued })
+
+	//act
+	_, skipErr := r.Trigger(context.Background())
+	close(release)
+	wg.Wait()
+
+	//assert
+	assert.Equal(t, ErrSkipped, skipErr)
+	assert.NoError(t, <-errs)
+	assert.NoError(t, <-errs)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&started))
+	assert.False(t, r.State().Queued)
+}
+
+func TestWaitPolicyHonoursContext(t *testing.T) {
+	//arrange
+	var started int32
+	release := make(chan struct{})
+	r, err := New(context.Background(), PolicyWait, blocking(&started, release))
+	assert.NoError(t, err)
+	wg := &sync.WaitGroup{}
+	errs := make(chan error, 1)
+	trigger(r, wg, errs)
+	waitFor(t, func() bool { return atomic.LoadInt32(&started) == 1 })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	//act
+	_, cancelErr := r.Trigger(ctx)
+	close(release)
+	wg.Wait()
+
+	//assert
+	assert.Equal(t, context.Canceled, cancelErr)
+	assert.NoError(t, <-errs)
+}
+
+func TestRunIDAndState(t *testing.T) {
+	//arrange
+	failure := errors.New("failure")
+	var seen string
+	r, err := New(context.Background(), "", func(ctx context.Context) error {
+		seen = ID(ctx)
+		return failure
+	})
+	assert.NoError(t, err)
+
+	//act
+	first, firstErr := r.Trigger(context.Background())
+	second, _ := r.Trigger(context.Background())
+	state := r.State()
+
+	//assert
+	assert.Equal(t, failure, firstErr)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, second, seen)
+	assert.Equal(t, second, state.RunID)
+	assert.False(t, state.Running)
+	assert.Equal(t, failure, state.LastError)
+	assert.Equal(t, "", ID(context.Background()))
+}
+
+func TestUnknownPolicy(t *testing.T) {
+	//act
+	_, err := New(context.Background(), "parallel", nil)
+
+	//assert
+	assert.Error(t, err)
+}
+
+func TestStopWaitsForRun(t *testing.T) {
+	//arrange
+	var started int32
+	release := make(chan struct{})
+	r, err := New(context.Background(), PolicyWait, blocking(&started, release))
+	assert.NoError(t, err)
+	wg := &sync.WaitGroup{}
+	errs := make(chan error, 2)
+	trigger(r, wg, errs)
+	waitFor(t, func() bool { return atomic.LoadInt32(&started) == 1 })
+	trigger(r, wg, errs)
+	stopErrs := make(chan error, 1)
+
+	//act
+	go func() { stopErrs <- r.Stop(context.Background()) }()
+	waitFor(t, func() bool { return len(errs) == 1 })
+	close(release)
+	wg.Wait()
+	_, afterErr := r.Trigger(context.Background())
+
+	//assert
+	assert.Equal(t, ErrStopped, <-errs)
+	assert.NoError(t, <-errs)
+	assert.NoError(t, <-stopErrs)
+	assert.Equal(t, ErrStopped, afterErr)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&started))
+}
+
+func TestStopCancelsRunAfterDeadline(t *testing.T) {
+	//arrange
+	running := make(chan struct{})
+	r, err := New(context.Background(), PolicySkip, func(ctx context.Context) error {
+		close(running)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.NoError(t, err)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := r.Trigger(context.Background())
+		errs <- err
+	}()
+	<-running
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	//act
+	stopErr := r.Stop(ctx)
+
+	//assert
+	assert.Equal(t, context.DeadlineExceeded, stopErr)
+	assert.Equal(t, context.Canceled, <-errs)
+	assert.Equal(t, context.Canceled, r.State().LastError)
+}
+
+func TestDoSharesSlotWithScheduledJob(t *testing.T) {
+	//arrange
+	var started int32
+	release := make(chan struct{})
+	r, err := New(context.Background(), PolicySkip, blocking(&started, release))
+	assert.NoError(t, err)
+	wg := &sync.WaitGroup{}
+	errs := make(chan error, 1)
+	trigger(r, wg, errs)
+	waitFor(t, func() bool { return atomic.LoadInt32(&started) == 1 })
+	manual := func(ctx context.Context) error { return nil }
+
+	//act
+	_, busyErr := r.Do(context.Background(), manual)
+	close(release)
+	wg.Wait()
+	_, doErr := r.Do(context.Background(), manual)
+
+	//assert
+	assert.Equal(t, ErrSkipped, busyErr)
+	assert.NoError(t, doErr)
+	assert.NoError(t, <-errs)
+}
@@ -0,0 +1,152 @@
+package selector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// orders
+const (
+	OrderWalk  = ""
+	OrderMtime = "mtime"
+	OrderName  = "name"
+	OrderSize  = "size"
+)
+
+// error messages
+const (
+	ErrUnknownOrder = "unknown order "
+)
+
+//Candidate is file picked by selector and ready for download
+type Candidate struct {
+	//Path is remote path of file
+	Path string
+	//Rel is path relative to the remote root
+	Rel     string
+	Size    int64
+	ModTime time.Time
+	//Markers signalling readiness of file
+	Markers []string
+}
+
+//PlanOptions configures order and caps of files downloaded by single run
+type PlanOptions struct {
+	//Order is one of mtime (oldest first), name or size (smallest first). Empty order keeps order of walker
+	Order string
+	//MaxFiles is maximal number of files per run, 0 means unlimited
+	MaxFiles int
+	//MaxBytes is maximal total size of files per run, 0 means unlimited. The first file is always planned
+	//even if it is bigger, so huge file cannot get stuck
+	MaxBytes int64
+	//Fair takes files round-robin across partner folders, so one huge backlog cannot starve others
+	Fair bool
+}
+
+//Planner orders candidates and applies per run caps. Fair planner remembers which partners it served,
+//so the same planner must be used by all runs
+type Planner struct {
+	options PlanOptions
+	less    func(a, b Candidate) bool
+
+	mu sync.Mutex
+	//served is turn partner was served last time, turn is incremented by every planned file
+	served map[string]uint64
+	turn   uint64
+}
+
+//NewPlanner validates options and creates planner
+func NewPlanner(options PlanOptions) (*Planner, error) {
+	p := &Planner{options: options}
+	switch strings.ToLower(options.Order) {
+	case OrderWalk:
+	case OrderMtime:
+		p.less = func(a, b Candidate) bool { return a.ModTime.Before(b.ModTime) }
+	case OrderName:
+		p.less = func(a, b Candidate) bool { return a.Rel < b.Rel }
+	case OrderSize:
+		p.less = func(a, b Candidate) bool { return a.Size < b.Size }
+	default:
+		return nil, errors.New(ErrUnknownOrder + options.Order)
+	}
+	return p, nil
+}
+
+//Plan returns candidates which are downloaded in this run, in order they are downloaded
+func (p *Planner) Plan(candidates []Candidate) []Candidate {
+	ordered := make([]Candidate, len(candidates))
+	copy(ordered, candidates)
+	if p.less != nil {
+		sort.SliceStable(ordered, func(i, j int) bool { return p.less(ordered[i], ordered[j]) })
+	}
+	if p.options.Fair {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		ordered = roundRobin(ordered, p.served)
+	}
+
+	var planned []Candidate
+	var bytes int64
+	for _, c := range ordered {
+		if p.options.MaxFiles > 0 && len(planned) >= p.options.MaxFiles {
+			break
+		}
+		if p.options.MaxBytes > 0 && len(planned) > 0 && bytes+c.Size > p.options.MaxBytes {
+			break
+		}
+		planned = append(planned, c)
+		bytes += c.Size
+	}
+	if p.options.Fair {
+		p.remember(planned)
+	}
+	return planned
+}
+
+//remember records partners of planned files, so the next run starts with partners which waited longest
+func (p *Planner) remember(planned []Candidate) {
+	if p.served == nil {
+		p.served = map[string]uint64{}
+	}
+	for _, c := range planned {
+		p.turn++
+		p.served[partnerOf(c.Rel)] = p.turn
+	}
+}
+
+//roundRobin interleaves ordered candidates by partner folder. Partners take turns starting by the one served
+//least recently, partners never served go first in order of their first candidate
+func roundRobin(ordered []Candidate, served map[string]uint64) []Candidate {
+	var partners []string
+	queues := map[string][]Candidate{}
+	for _, c := range ordered {
+		partner := partnerOf(c.Rel)
+		if _, ok := queues[partner]; !ok {
+			partners = append(partners, partner)
+		}
+		queues[partner] = append(queues[partner], c)
+	}
+	sort.SliceStable(partners, func(i, j int) bool { return served[partners[i]] < served[partners[j]] })
+	interleaved := make([]Candidate, 0, len(ordered))
+	for len(interleaved) < len(ordered) {
+		for _, partner := range partners {
+			if queue := queues[partner]; len(queue) > 0 {
+				interleaved = append(interleaved, queue[0])
+				queues[partner] = queue[1:]
+			}
+		}
+	}
+	return interleaved
+}
+
+//partnerOf returns the first folder of rel, files in the remote root belong to "."
+func partnerOf(rel string) string {
+	if i := strings.Index(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	return "."
+}
@@ -0,0 +1,95 @@
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2018, 8, 8, 12, 0, 0, 0, time.UTC)
+
+var candidates = []Candidate{
+	{Rel: "COBA/KV03.zip", Size: 30, ModTime: now.Add(-1 * time.Hour)},
+	{Rel: "COBA/KV01.zip", Size: 10, ModTime: now.Add(-3 * time.Hour)},
+	{Rel: "COBA/KV02.zip", Size: 20, ModTime: now.Add(-2 * time.Hour)},
+	{Rel: "BRCLS/KV04.zip", Size: 5, ModTime: now.Add(-30 * time.Minute)},
+	{Rel: "DBK/KV05.zip", Size: 50, ModTime: now},
+}
+
+func rels(planned []Candidate) []string {
+	var result []string
+	for _, c := range planned {
+		result = append(result, c.Rel)
+	}
+	return result
+}
+
+func TestPlanOrders(t *testing.T) {
+	for order, expected := range map[string][]string{
+		OrderWalk:  {"COBA/KV03.zip", "COBA/KV01.zip", "COBA/KV02.zip", "BRCLS/KV04.zip", "DBK/KV05.zip"},
+		OrderMtime: {"COBA/KV01.zip", "COBA/KV02.zip", "COBA/KV03.zip", "BRCLS/KV04.zip", "DBK/KV05.zip"},
+		OrderName:  {"BRCLS/KV04.zip", "COBA/KV01.zip", "COBA/KV02.zip", "COBA/KV03.zip", "DBK/KV05.zip"},
+		OrderSize:  {"BRCLS/KV04.zip", "COBA/KV01.zip", "COBA/KV02.zip", "COBA/KV03.zip", "DBK/KV05.zip"},
+	} {
+		//arrange
+		p, err := NewPlanner(PlanOptions{Order: order})
+		assert.NoError(t, err)
+
+		//act
+		planned := p.Plan(candidates)
+
+		//assert
+		assert.Equal(t, expected, rels(planned), order)
+	}
+}
+
+func TestPlanCaps(t *testing.T) {
+	//arrange
+	byFiles, _ := NewPlanner(PlanOptions{Order: OrderMtime, MaxFiles: 2})
+	byBytes, _ := NewPlanner(PlanOptions{Order: OrderMtime, MaxBytes: 35})
+	hugeFirst, _ := NewPlanner(PlanOptions{Order: OrderMtime, MaxBytes: 1})
+
+	//act
+	//assert
+	assert.Equal(t, []string{"COBA/KV01.zip", "COBA/KV02.zip"}, rels(byFiles.Plan(candidates)))
+	assert.Equal(t, []string{"COBA/KV01.zip", "COBA/KV02.zip"}, rels(byBytes.Plan(candidates)))
+	assert.Equal(t, []string{"COBA/KV01.zip"}, rels(hugeFirst.Plan(candidates)), "first file is always planned")
+}
+
+func TestPlanFair(t *testing.T) {
+	//arrange
+	p, err := NewPlanner(PlanOptions{Order: OrderMtime, Fair: true, MaxFiles: 3})
+	assert.NoError(t, err)
+
+	//act
+	planned := p.Plan(candidates)
+
+	//assert
+	assert.Equal(t, []string{"COBA/KV01.zip", "BRCLS/KV04.zip", "DBK/KV05.zip"}, rels(planned))
+}
+
+func TestPlanFairAcrossRuns(t *testing.T) {
+	//arrange
+	p, err := NewPlanner(PlanOptions{Order: OrderMtime, Fair: true, MaxFiles: 1})
+	assert.NoError(t, err)
+
+	//act
+	var served []string
+	for run := 0; run < 4; run++ {
+		served = append(served, rels(p.Plan(candidates))...)
+	}
+
+	//assert
+	assert.Equal(t, []string{"COBA/KV01.zip", "BRCLS/KV04.zip", "DBK/KV05.zip", "COBA/KV01.zip"}, served,
+		"partners take turns across runs")
+}
+
+func TestUnknownOrder(t *testing.T) {
+	//arrange
+	//act
+	_, err := NewPlanner(PlanOptions{Order: "random"})
+
+	//assert
+	assert.Error(t, err)
+}
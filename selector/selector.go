@@ -0,0 +1,170 @@
+package selector
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//regexPrefix marks pattern as regular expression, other patterns are globs
+const regexPrefix = "re:"
+
+// error messages
+const (
+	ErrInvalidPattern   = "invalid pattern "
+	ErrInvalidSubfolder = "invalid subfolder "
+)
+
+//Options configures file selection. Patterns are case insensitive globs (see path.Match) or regular expressions
+//prefixed by `re:` matched against path relative to the remote root. Only legacy FileMask is matched against file name
+type Options struct {
+	//FileMask is legacy single include glob matched against file name in any folder
+	FileMask string
+	//Include patterns, file must match at least one of FileMask and Include
+	Include []string
+	//Exclude patterns, file matching any of them is skipped
+	Exclude []string
+	//ExcludeDirs patterns, matching folders are not walked at all
+	ExcludeDirs []string
+	//MaxDepth is maximal number of folder levels below the remote root which are walked, 0 means unlimited
+	MaxDepth int
+	//Subfolders is allow-list of folders relative to the remote root, i.e. `COBA` or `BRCLS/in`. When set,
+	//only files inside listed folders are picked and other folders are not walked
+	Subfolders []string
+}
+
+//Selector decides which remote files are picked and which folders are walked
+type Selector struct {
+	include     []matcher
+	exclude     []matcher
+	excludeDirs []matcher
+	maxDepth    int
+	subfolders  []string
+}
+
+type matcher func(rel string) bool
+
+//New compiles patterns of options
+func New(options Options) (*Selector, error) {
+	s := &Selector{maxDepth: options.MaxDepth}
+	if options.FileMask != "" {
+		mask, err := compileOne(options.FileMask)
+		if err != nil {
+			return nil, err
+		}
+		s.include = append(s.include, func(rel string) bool { return mask(path.Base(rel)) })
+	}
+	for _, subfolder := range options.Subfolders {
+		folder := strings.ToLower(strings.Trim(path.Clean("/"+subfolder), "/"))
+		if folder == "" {
+			return nil, errors.New(ErrInvalidSubfolder + subfolder)
+		}
+		s.subfolders = append(s.subfolders, folder)
+	}
+	include, err := compile(options.Include)
+	if err != nil {
+		return nil, err
+	}
+	s.include = append(s.include, include...)
+	if s.exclude, err = compile(options.Exclude); err != nil {
+		return nil, err
+	}
+	if s.excludeDirs, err = compile(options.ExcludeDirs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//Match returns true if file with path rel relative to remote root is selected
+func (s *Selector) Match(rel string) bool {
+	dir := path.Dir(rel)
+	if s.maxDepth > 0 && depth(dir) > s.maxDepth {
+		return false
+	}
+	if len(s.subfolders) > 0 && !s.inSubfolder(dir) {
+		return false
+	}
+	return matchAny(s.include, rel) && !matchAny(s.exclude, rel)
+}
+
+//SkipDir returns true if folder with path rel relative to remote root must not be walked
+func (s *Selector) SkipDir(rel string) bool {
+	if s.maxDepth > 0 && depth(rel) > s.maxDepth {
+		return true
+	}
+	if len(s.subfolders) > 0 && !s.inSubfolder(rel) && !s.aboveSubfolder(rel) {
+		return true
+	}
+	return matchAny(s.excludeDirs, rel)
+}
+
+//inSubfolder returns true if folder dir is one of allowed subfolders or lies inside of one
+func (s *Selector) inSubfolder(dir string) bool {
+	dir = strings.ToLower(dir)
+	for _, folder := range s.subfolders {
+		if dir == folder || strings.HasPrefix(dir, folder+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+//aboveSubfolder returns true if folder dir must be walked to reach some allowed subfolder
+func (s *Selector) aboveSubfolder(dir string) bool {
+	dir = strings.ToLower(dir)
+	for _, folder := range s.subfolders {
+		if dir == "." || strings.HasPrefix(folder, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+//depth returns number of folder levels of rel, remote root has depth 0
+func depth(rel string) int {
+	if rel == "." || rel == "" {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
+
+func matchAny(matchers []matcher, rel string) bool {
+	for _, m := range matchers {
+		if m(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func compile(patterns []string) ([]matcher, error) {
+	var matchers []matcher
+	for _, pattern := range patterns {
+		m, err := compileOne(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func compileOne(pattern string) (matcher, error) {
+	if strings.HasPrefix(pattern, regexPrefix) {
+		re, err := regexp.Compile("(?i)" + strings.TrimPrefix(pattern, regexPrefix))
+		if err != nil {
+			return nil, errors.Wrap(err, ErrInvalidPattern+pattern)
+		}
+		return re.MatchString, nil
+	}
+	glob := strings.ToLower(pattern)
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, errors.Wrap(err, ErrInvalidPattern+pattern)
+	}
+	return func(rel string) bool {
+		ok, _ := path.Match(glob, strings.ToLower(rel))
+		return ok
+	}, nil
+}
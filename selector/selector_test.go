@@ -0,0 +1,118 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMaskMatchesName(t *testing.T) {
+	//arrange
+	s, err := New(Options{FileMask: "KV*_T_EDT_*.zip"})
+	assert.NoError(t, err)
+
+	//act
+	//assert
+	assert.True(t, s.Match("COBA/KV1212_T_EDT_Bonds180808.zip"))
+	assert.True(t, s.Match("COBA/2018/kv1212_t_edt_bonds180808.ZIP"))
+	assert.False(t, s.Match("COBA/KV1212_T_EDT_Bonds180808.zip_0"))
+}
+
+func TestIncludeAndExclude(t *testing.T) {
+	//arrange
+	s, err := New(Options{
+		Include: []string{"BRCLS/*.zip", `re:^coba/kv\d+_t_edt_.*\.zip$`},
+		Exclude: []string{"*/*_Test*", "re:/draft/"},
+	})
+	assert.NoError(t, err)
+
+	//act
+	//assert
+	assert.True(t, s.Match("BRCLS/KV1212_T_EDT_Bonds180808.zip"))
+	assert.True(t, s.Match("COBA/KV1212_T_EDT_Bonds180808.zip"))
+	assert.False(t, s.Match("BRCLS/sub/KV1212_T_EDT_Bonds180808.zip"), "glob with / is matched against whole path")
+	assert.False(t, s.Match("COBA/KV1212_T_EDT_Test180808.zip"))
+	assert.False(t, s.Match("KV1212_T_EDT_Bonds180808.zip"))
+}
+
+func TestExcludeNestedPath(t *testing.T) {
+	//arrange
+	s, err := New(Options{FileMask: "KV*.zip", Exclude: []string{"re:/draft/", "*/*/*_Test*", "*_Test*"}})
+	assert.NoError(t, err)
+
+	//act
+	//assert
+	assert.True(t, s.Match("BRCLS/2018/KV1212_T_EDT_Bonds180808.zip"), "FileMask is matched against file name at any depth")
+	assert.False(t, s.Match("BRCLS/draft/KV1212_T_EDT_Bonds180808.zip"), "regular expression is matched against whole path")
+	assert.False(t, s.Match("BRCLS/2018/KV1212_T_EDT_Test180808.zip"))
+	assert.True(t, s.Match("BRCLS/KV1212_T_EDT_Test180808.zip"), "glob is matched against whole path, * doesn't match /")
+	assert.False(t, s.Match("KV1212_T_EDT_Test180808.zip"))
+	assert.True(t, s.Match("BRCLS/drafts/KV1212_T_EDT_Bonds180808.zip"))
+}
+
+func TestExcludeDirs(t *testing.T) {
+	//arrange
+	s, err := New(Options{FileMask: "*.zip", ExcludeDirs: []string{"*/archive", "re:^COBA/tmp"}})
+	assert.NoError(t, err)
+
+	//act
+	//assert
+	assert.True(t, s.SkipDir("BRCLS/archive"))
+	assert.False(t, s.SkipDir("archive"), "glob is matched against whole path")
+	assert.True(t, s.SkipDir("COBA/tmp"))
+	assert.False(t, s.SkipDir("BRCLS/tmp"))
+	assert.False(t, s.SkipDir("COBA"))
+}
+
+func TestInvalidPatterns(t *testing.T) {
+	//arrange
+	//act
+	_, errGlob := New(Options{FileMask: "KV[*.zip"})
+	_, errRegex := New(Options{Exclude: []string{"re:(unclosed"}})
+
+	//assert
+	assert.Error(t, errGlob)
+	assert.Error(t, errRegex)
+}
+
+func TestMaxDepth(t *testing.T) {
+	//arrange
+	s, err := New(Options{FileMask: "*.zip", MaxDepth: 1})
+	assert.NoError(t, err)
+
+	//act
+	//assert
+	assert.False(t, s.SkipDir("COBA"))
+	assert.True(t, s.SkipDir("COBA/2018"))
+	assert.True(t, s.Match("KV1212.zip"))
+	assert.True(t, s.Match("COBA/KV1212.zip"))
+	assert.False(t, s.Match("COBA/2018/KV1212.zip"))
+}
+
+func TestSubfolders(t *testing.T) {
+	//arrange
+	s, err := New(Options{FileMask: "*.zip", Subfolders: []string{"coba", "/BRCLS/in/"}})
+	assert.NoError(t, err)
+
+	//act
+	//assert
+	assert.False(t, s.SkipDir("COBA"))
+	assert.False(t, s.SkipDir("COBA/2018"))
+	assert.False(t, s.SkipDir("BRCLS"), "ancestor of allowed folder is walked")
+	assert.False(t, s.SkipDir("BRCLS/in"))
+	assert.True(t, s.SkipDir("BRCLS/out"))
+	assert.True(t, s.SkipDir("DBK"))
+	assert.True(t, s.Match("COBA/2018/KV1212.zip"))
+	assert.True(t, s.Match("BRCLS/in/KV1212.zip"))
+	assert.False(t, s.Match("BRCLS/KV1212.zip"))
+	assert.False(t, s.Match("KV1212.zip"))
+}
+
+func TestInvalidSubfolder(t *testing.T) {
+	//arrange
+	//act
+	_, err := New(Options{Subfolders: []string{"/"}})
+
+	//assert
+	assert.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exporters of spans
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// error messages
+const (
+	ErrUnknownExporter = "unknown trace exporter "
+)
+
+// attributes of spans
+const (
+	KeyRunID   = attribute.Key("edt.run_id")
+	KeyPartner = attribute.Key("edt.partner")
+	KeyFile    = attribute.Key("edt.file")
+	KeySize    = attribute.Key("edt.size")
+)
+
+//service is name of the service spans are reported by
+const service = "edt-sftp"
+
+//tracer is name of instrumentation library
+const tracer = "github.com/Deutsche-Boerse/edt-sftp"
+
+//Setup installs global tracer provider exporting spans to stdout or to OTLP HTTP endpoint, i.e. http://collector:4318.
+//Empty endpoint falls back to OTEL_EXPORTER_OTLP_ENDPOINT. Returned function flushes spans and stops exporting.
+//Without setup spans are not recorded
+func Setup(ctx context.Context, exporter string, endpoint string) (func(context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(exporter) {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		spanExporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, errors.New(ErrUnknownExporter + exporter)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create %s trace exporter", exporter)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", service)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(spanExporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+//Start starts span which is child of span in ctx
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracer).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+//End records error of span, if any, and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+//File returns attributes identifying file of partner
+func File(partner string, file string, size int64) []attribute.KeyValue {
+	return []attribute.KeyValue{KeyPartner.String(partner), KeyFile.String(file), KeySize.Int64(size)}
+}
+
+//Inject propagates trace context of ctx into headers of outgoing request
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+//Sequence traces items processed one after another by loop. Span of item ends when the next item starts
+//or End is called, so loops with many exits need a single deferred End only
+type Sequence struct {
+	ctx  context.Context
+	name string
+	span trace.Span
+	err  *error
+}
+
+//NewSequence creates sequence of spans called name, children of span in ctx
+func NewSequence(ctx context.Context, name string) *Sequence {
+	return &Sequence{ctx: ctx, name: name}
+}
+
+//Next ends span of previous item and starts span of the next one. Error err points to is recorded when the span ends
+func (s *Sequence) Next(err *error, attributes ...attribute.KeyValue) context.Context {
+	s.End()
+	var ctx context.Context
+	ctx, s.span = Start(s.ctx, s.name, attributes...)
+	s.err = err
+	return ctx
+}
+
+//End ends span of the current item
+func (s *Sequence) End() {
+	if s.span == nil {
+		return
+	}
+	End(s.span, *s.err)
+	s.span, s.err = nil, nil
+}
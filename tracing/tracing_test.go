@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func record() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+func TestSequenceEndsSpanOfItemWhenNextStarts(t *testing.T) {
+	//arrange
+	recorder := record()
+	ctx, parent := Start(context.Background(), "unzip")
+	first, second := errors.New("corrupted"), error(nil)
+
+	//act
+	spans := NewSequence(ctx, "unzip")
+	spans.Next(&first, File("COBA", "a.zip", 1)...)
+	spans.Next(&second, File("COBA", "b.zip", 2)...)
+	spans.End()
+	parent.End()
+
+	//assert
+	ended := recorder.Ended()
+	assert.Equal(t, 3, len(ended))
+	assert.Equal(t, codes.Error, ended[0].Status().Code)
+	assert.Equal(t, codes.Unset, ended[1].Status().Code)
+	assert.Equal(t, parent.SpanContext().SpanID(), ended[0].Parent().SpanID())
+	assert.Contains(t, ended[1].Attributes(), KeyFile.String("b.zip"))
+}
+
+func TestInjectPropagatesTraceContext(t *testing.T) {
+	//arrange
+	record()
+	ctx, span := Start(context.Background(), "send")
+	defer span.End()
+	header := http.Header{}
+
+	//act
+	Inject(ctx, header)
+
+	//assert
+	assert.Contains(t, header.Get("traceparent"), span.SpanContext().TraceID().String())
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	//act
+	_, err := Setup(context.Background(), "jaeger", "")
+
+	//assert
+	assert.Error(t, err)
+}
+
+func TestSetupWithoutExporterDoesNothing(t *testing.T) {
+	//act
+	shutdown, err := Setup(context.Background(), ExporterNone, "")
+
+	//assert
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
@@ -0,0 +1,98 @@
+package unzip
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+)
+
+//ErrTooBig is returned when archive would be inflated into more bytes than allowed
+var ErrTooBig = errors.New("zip: uncompressed size exceeds limit")
+
+//Archive reads entries of zip archive on demand. Nothing is extracted in advance, every entry is inflated
+//while it is read, so the content is never held in memory or written to disk
+type Archive struct {
+	r        io.ReaderAt
+	password string
+	names    []string
+	files    map[string]*zip.File
+}
+
+//OpenArchive reads directory of archive. Archive whose entries declare more than maxSize uncompressed bytes
+//in total is refused, zero maxSize disables the limit. Password of encrypted entries is checked
+func OpenArchive(r io.ReaderAt, size int64, password string, maxSize int64) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{r: r, password: password, files: map[string]*zip.File{}}
+	var total uint64
+	for _, f := range zr.File {
+		total += f.UncompressedSize64
+		if maxSize > 0 && total > uint64(maxSize) {
+			return nil, ErrTooBig
+		}
+		if isEncrypted(f) {
+			rc, err := openEncrypted(r, f, password)
+			if err != nil {
+				return nil, err
+			}
+			rc.Close()
+		}
+		a.names = append(a.names, f.Name)
+		a.files[f.Name] = f
+	}
+	return a, nil
+}
+
+//Names returns names of entries in order they are stored in archive, folders included
+func (a *Archive) Names() []string {
+	return a.names
+}
+
+//IsFile returns false for folders and unknown entries
+func (a *Archive) IsFile(name string) bool {
+	f, ok := a.files[name]
+	return ok && !f.FileInfo().IsDir()
+}
+
+//Open returns reader of entry. Reading more bytes than the entry declares fails with ErrTooBig,
+//so total size checked by OpenArchive cannot be exceeded
+func (a *Archive) Open(name string) (io.ReadCloser, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	rc, err := open(a.r, f, a.password)
+	if err != nil {
+		return nil, err
+	}
+	return &limitedEntry{ReadCloser: rc, remaining: int64(f.UncompressedSize64)}, nil
+}
+
+type limitedEntry struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (e *limitedEntry) Read(p []byte) (int, error) {
+	if e.remaining <= 0 {
+		//declared size is reached, anything more is refused
+		var one [1]byte
+		n, err := e.ReadCloser.Read(one[:])
+		if n > 0 {
+			return 0, ErrTooBig
+		}
+		if err == nil {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	if int64(len(p)) > e.remaining {
+		p = p[:e.remaining]
+	}
+	n, err := e.ReadCloser.Read(p)
+	e.remaining -= int64(n)
+	return n, err
+}
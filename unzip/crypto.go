@@ -0,0 +1,323 @@
+package unzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// encryption related constants from PKWARE APPNOTE and WinZip AES specification
+const (
+	flagEncrypted      = 0x1
+	flagDataDescriptor = 0x8
+	methodAes          = 99
+	aesExtraID         = 0x9901
+	aesIterations      = 1000
+	aesPwvLen          = 2
+	aesAuthCodeLen     = 10
+	zipCryptoHeaderLen = 12
+)
+
+var (
+	ErrPasswordRequired = errors.New("zip: password required for encrypted entry")
+	ErrPassword         = errors.New("zip: invalid password")
+	ErrAuthentication   = errors.New("zip: authentication code mismatch")
+	ErrAlgorithm        = errors.New("zip: unsupported compression algorithm")
+	ErrChecksum         = errors.New("zip: checksum error")
+	ErrAesExtra         = errors.New("zip: missing or invalid AES extra field")
+)
+
+//isEncrypted returns true when entry is protected either by ZipCrypto or AES
+func isEncrypted(f *zip.File) bool {
+	return f.Flags&flagEncrypted != 0 || f.Method == methodAes
+}
+
+//openEncrypted decrypts and decompresses entry f read from archive r
+func openEncrypted(r io.ReaderAt, f *zip.File, password string) (io.ReadCloser, error) {
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+	offset, err := f.DataOffset()
+	if err != nil {
+		return nil, err
+	}
+	raw := io.NewSectionReader(r, offset, int64(f.CompressedSize64))
+	if f.Method == methodAes {
+		return openAes(raw, f, []byte(password))
+	}
+	return openZipCrypto(raw, f, []byte(password))
+}
+
+// ZipCrypto - traditional PKWARE encryption
+
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password []byte) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range password {
+		k.update(b)
+	}
+	return k
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ (crc >> 8)
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+func (k *zipCryptoKeys) decrypt(b byte) byte {
+	t := k[2] | 2
+	c := b ^ byte((t*(t^1))>>8)
+	k.update(c)
+	return c
+}
+
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] = z.keys.decrypt(p[i])
+	}
+	return n, err
+}
+
+func openZipCrypto(raw *io.SectionReader, f *zip.File, password []byte) (io.ReadCloser, error) {
+	keys := newZipCryptoKeys(password)
+	var header [zipCryptoHeaderLen]byte
+	if _, err := io.ReadFull(raw, header[:]); err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = keys.decrypt(header[i])
+	}
+	//the last byte of the header verifies password. When data descriptor is used, the CRC is not known
+	//in time of writing local header so high byte of modification time is used instead
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&flagDataDescriptor != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	if header[zipCryptoHeaderLen-1] != check {
+		return nil, ErrPassword
+	}
+	data := io.NewSectionReader(raw, zipCryptoHeaderLen, raw.Size()-zipCryptoHeaderLen)
+	rc, err := decompress(f.Method, &zipCryptoReader{r: data, keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	return &checksumReader{rc: rc, hash: crc32.NewIEEE(), crc: f.CRC32, size: f.UncompressedSize64}, nil
+}
+
+// WinZip AES
+
+type aesExtra struct {
+	version  uint16
+	strength byte
+	method   uint16
+}
+
+func readAesExtra(extra []byte) (*aesExtra, error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		if id == aesExtraID && size == 7 {
+			return &aesExtra{
+				version:  binary.LittleEndian.Uint16(extra[0:2]),
+				strength: extra[4],
+				method:   binary.LittleEndian.Uint16(extra[5:7]),
+			}, nil
+		}
+		extra = extra[size:]
+	}
+	return nil, ErrAesExtra
+}
+
+func aesKeyLen(strength byte) int {
+	switch strength {
+	case 1:
+		return 16
+	case 2:
+		return 24
+	case 3:
+		return 32
+	}
+	return 0
+}
+
+func openAes(raw *io.SectionReader, f *zip.File, password []byte) (io.ReadCloser, error) {
+	extra, err := readAesExtra(f.Extra)
+	if err != nil {
+		return nil, err
+	}
+	keyLen := aesKeyLen(extra.strength)
+	if keyLen == 0 {
+		return nil, ErrAesExtra
+	}
+	saltLen := keyLen / 2
+	dataLen := raw.Size() - int64(saltLen+aesPwvLen+aesAuthCodeLen)
+	if dataLen < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	head := make([]byte, saltLen+aesPwvLen)
+	if _, err = io.ReadFull(raw, head); err != nil {
+		return nil, err
+	}
+	keys := pbkdf2.Key(password, head[:saltLen], aesIterations, 2*keyLen+aesPwvLen, sha1.New)
+	if !bytes.Equal(keys[2*keyLen:], head[saltLen:]) {
+		return nil, ErrPassword
+	}
+	block, err := aes.NewCipher(keys[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	auth := &authReader{
+		data: io.NewSectionReader(raw, int64(len(head)), dataLen),
+		code: io.NewSectionReader(raw, int64(len(head))+dataLen, aesAuthCodeLen),
+		mac:  hmac.New(sha1.New, keys[keyLen:2*keyLen]),
+	}
+	rc, err := decompress(extra.method, cipher.StreamReader{S: newWinZipCtr(block), R: auth})
+	if err != nil {
+		return nil, err
+	}
+	rc = &authenticatedReader{rc: rc, auth: auth}
+	//AE-2 doesn't store CRC, integrity is guaranteed by authentication code
+	if extra.version == 2 {
+		return rc, nil
+	}
+	return &checksumReader{rc: rc, hash: crc32.NewIEEE(), crc: f.CRC32, size: f.UncompressedSize64}, nil
+}
+
+//winZipCtr is AES-CTR with little endian counter starting at 1, as WinZip requires
+type winZipCtr struct {
+	block     cipher.Block
+	counter   [aes.BlockSize]byte
+	keystream [aes.BlockSize]byte
+	used      int
+}
+
+func newWinZipCtr(block cipher.Block) *winZipCtr {
+	return &winZipCtr{block: block, used: aes.BlockSize}
+}
+
+func (x *winZipCtr) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if x.used == aes.BlockSize {
+			for j := range x.counter {
+				x.counter[j]++
+				if x.counter[j] != 0 {
+					break
+				}
+			}
+			x.block.Encrypt(x.keystream[:], x.counter[:])
+			x.used = 0
+		}
+		dst[i] = src[i] ^ x.keystream[x.used]
+		x.used++
+	}
+}
+
+//authReader computes HMAC over the encrypted data and verifies it against stored code once data are read
+type authReader struct {
+	data io.Reader
+	code io.Reader
+	mac  hash.Hash
+	err  error
+}
+
+func (a *authReader) Read(p []byte) (int, error) {
+	if a.err != nil {
+		return 0, a.err
+	}
+	n, err := a.data.Read(p)
+	a.mac.Write(p[:n])
+	if err == io.EOF {
+		a.err = io.EOF
+		code, cerr := ioutil.ReadAll(a.code)
+		if cerr != nil {
+			a.err = cerr
+		} else if !hmac.Equal(code, a.mac.Sum(nil)[:aesAuthCodeLen]) {
+			a.err = ErrAuthentication
+		}
+		err = a.err
+	}
+	return n, err
+}
+
+//authenticatedReader makes sure the whole encrypted stream is consumed and verified,
+//decompressor may stop reading before the underlying data are exhausted
+type authenticatedReader struct {
+	rc   io.ReadCloser
+	auth *authReader
+}
+
+func (a *authenticatedReader) Read(p []byte) (int, error) {
+	n, err := a.rc.Read(p)
+	if err == io.EOF {
+		if _, aerr := io.Copy(ioutil.Discard, a.auth); aerr != nil {
+			return n, aerr
+		}
+	}
+	return n, err
+}
+
+func (a *authenticatedReader) Close() error {
+	return a.rc.Close()
+}
+
+// common
+
+func decompress(method uint16, r io.Reader) (io.ReadCloser, error) {
+	switch method {
+	case zip.Store:
+		return ioutil.NopCloser(r), nil
+	case zip.Deflate:
+		return flate.NewReader(r), nil
+	}
+	return nil, ErrAlgorithm
+}
+
+type checksumReader struct {
+	rc    io.ReadCloser
+	hash  hash.Hash32
+	crc   uint32
+	size  uint64
+	nread uint64
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	c.hash.Write(p[:n])
+	c.nread += uint64(n)
+	if err == io.EOF && (c.nread != c.size || c.hash.Sum32() != c.crc) {
+		return n, ErrChecksum
+	}
+	return n, err
+}
+
+func (c *checksumReader) Close() error {
+	return c.rc.Close()
+}
@@ -0,0 +1,37 @@
+package unzip
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+//Sink receives entries of unzipped archive
+type Sink interface {
+	//Create returns path and writer for entry name
+	Create(name string, mode os.FileMode) (string, io.WriteCloser, error)
+	//Mkdir creates folder for entry name and returns its path
+	Mkdir(name string) (string, error)
+}
+
+//DirSink writes entries into local folder
+type DirSink struct {
+	Dir string
+}
+
+//Create creates file and its parent folders
+func (s *DirSink) Create(name string, mode os.FileMode) (string, io.WriteCloser, error) {
+	fpath := filepath.Join(s.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+		return fpath, nil, err
+	}
+	f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	return fpath, f, err
+}
+
+//Mkdir creates folder
+func (s *DirSink) Mkdir(name string) (string, error) {
+	fpath := filepath.Join(s.Dir, name)
+	os.MkdirAll(fpath, os.ModePerm)
+	return fpath, nil
+}
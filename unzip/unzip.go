@@ -4,48 +4,67 @@ import (
 	"archive/zip"
 	"io"
 	"os"
-	"path/filepath"
 )
 
 //Unzip from source .zip to destination folder
 func Unzip(src string, dest string) ([]string, error) {
+	return UnzipWithPassword(src, dest, "")
+}
+
+//UnzipWithPassword from source .zip to destination folder. Entries encrypted by ZipCrypto or AES
+//are decrypted by password. Empty password is allowed for archives without encrypted entries
+func UnzipWithPassword(src string, dest string, password string) ([]string, error) {
+	var fileNames []string
+	file, err := os.Open(src)
+	if err != nil {
+		return fileNames, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return fileNames, err
+	}
+	return Extract(file, stat.Size(), password, &DirSink{Dir: dest})
+}
+
+//Extract unzips archive read from r into sink and returns paths of extracted entries
+func Extract(r io.ReaderAt, size int64, password string, sink Sink) ([]string, error) {
 	var fileNames []string
-	r, err := zip.OpenReader(src)
+	zr, err := zip.NewReader(r, size)
 	if err != nil {
 		return fileNames, err
 	}
-	defer r.Close()
-	for _, f := range r.File {
-		rc, err := f.Open()
+	for _, f := range zr.File {
+		rc, err := open(r, f, password)
 		if err != nil {
 			return fileNames, err
 		}
 		defer rc.Close()
 
-		// Store filename/path for returning and using later on
-		fpath := filepath.Join(dest, f.Name)
-		fileNames = append(fileNames, fpath)
-
 		if f.FileInfo().IsDir() {
 			// Make Folder
-			os.MkdirAll(fpath, os.ModePerm)
+			fpath, err := sink.Mkdir(f.Name)
+			if err != nil {
+				return fileNames, err
+			}
+			fileNames = append(fileNames, fpath)
 			continue
 		}
 
 		// Make File
-		if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
-			return fileNames, err
-		}
-
-		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+		fpath, outFile, err := sink.Create(f.Name, f.Mode())
 		if err != nil {
 			return fileNames, err
 		}
+		// Store filename/path for returning and using later on
+		fileNames = append(fileNames, fpath)
 
 		_, err = io.Copy(outFile, rc)
 
 		// Close the file without defer to close before next iteration of loop
-		outFile.Close()
+		if cerr := outFile.Close(); err == nil {
+			err = cerr
+		}
 
 		if err != nil {
 			return fileNames, err
@@ -53,3 +72,10 @@ func Unzip(src string, dest string) ([]string, error) {
 	}
 	return fileNames, nil
 }
+
+func open(r io.ReaderAt, f *zip.File, password string) (io.ReadCloser, error) {
+	if isEncrypted(f) {
+		return openEncrypted(r, f, password)
+	}
+	return f.Open()
+}
@@ -1,6 +1,8 @@
 package unzip
 
 import (
+	"bytes"
+	"io/ioutil"
 	"path/filepath"
 	"testing"
 
@@ -77,6 +79,98 @@ func TestUnzipTwoZipFilesInRow(t *testing.T) {
 	assert.True(t, linq.From(unzippedFiles).Contains(filepath.Join(testData.OutPath, "README.md")), "expected "+filepath.Join(testData.OutPath, "README.md"))
 }
 
+func TestUnzipZipCryptoFile(t *testing.T) {
+	//arrange
+
+	//act
+	unzippedFiles, err := UnzipWithPassword(filepath.Join(testData.InPath, "KV0011_T_EDT_ZipCrypto.zip"), testData.OutPath, "secret")
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(unzippedFiles))
+	assert.True(t, linq.From(unzippedFiles).Contains(filepath.Join(testData.OutPath, "warrants.csv")), "expected "+filepath.Join(testData.OutPath, "warrants.csv"))
+	assertContent(t, filepath.Join(testData.OutPath, "terms.xml"), "<edt>encrypted</edt>\n")
+}
+
+func TestUnzipAesFile(t *testing.T) {
+	//arrange
+
+	//act
+	unzippedFiles, err := UnzipWithPassword(filepath.Join(testData.InPath, "KV0011_T_EDT_Aes.zip"), testData.OutPath, "secret")
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(unzippedFiles))
+	assert.True(t, linq.From(unzippedFiles).Contains(filepath.Join(testData.OutPath, "terms.xml")), "expected "+filepath.Join(testData.OutPath, "terms.xml"))
+	assertContent(t, filepath.Join(testData.OutPath, "warrants.csv"), "ISIN;NAME\nDE0001;Warrant\n")
+}
+
+func TestUnzipEncryptedFileWithoutPassword(t *testing.T) {
+	//arrange
+
+	//act
+	_, err := Unzip(filepath.Join(testData.InPath, "KV0011_T_EDT_Aes.zip"), testData.OutPath)
+
+	//assert
+	assert.Equal(t, ErrPasswordRequired, err)
+}
+
+func TestUnzipEncryptedFileWithWrongPassword(t *testing.T) {
+	//arrange
+
+	//act
+	_, errAes := UnzipWithPassword(filepath.Join(testData.InPath, "KV0011_T_EDT_Aes.zip"), testData.OutPath, "wrong")
+	_, errZipCrypto := UnzipWithPassword(filepath.Join(testData.InPath, "KV0011_T_EDT_ZipCrypto.zip"), testData.OutPath, "wrong")
+
+	//assert
+	assert.Equal(t, ErrPassword, errAes)
+	assert.Equal(t, ErrPassword, errZipCrypto)
+}
+
+func TestOpenArchive(t *testing.T) {
+	//arrange
+	archive, err := ioutil.ReadFile(filepath.Join(testData.InPath, "KV0011_T_EDT_Aes.zip"))
+	assert.NoError(t, err)
+
+	//act
+	a, err := OpenArchive(bytes.NewReader(archive), int64(len(archive)), "secret", 1<<20)
+
+	//assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(a.Names()))
+	assert.True(t, a.IsFile("terms.xml"))
+	rc, err := a.Open("terms.xml")
+	assert.NoError(t, err)
+	defer rc.Close()
+	content, err := ioutil.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "<edt>encrypted</edt>\n", string(content))
+}
+
+func TestOpenArchiveOverLimit(t *testing.T) {
+	//arrange
+	archive, err := ioutil.ReadFile(filepath.Join(testData.InPath, "KV0011_T_EDT_Aes.zip"))
+	assert.NoError(t, err)
+
+	//act
+	_, err = OpenArchive(bytes.NewReader(archive), int64(len(archive)), "secret", 10)
+
+	//assert
+	assert.Equal(t, ErrTooBig, err)
+}
+
+func TestOpenArchiveWithWrongPassword(t *testing.T) {
+	//arrange
+	archive, err := ioutil.ReadFile(filepath.Join(testData.InPath, "KV0011_T_EDT_Aes.zip"))
+	assert.NoError(t, err)
+
+	//act
+	_, err = OpenArchive(bytes.NewReader(archive), int64(len(archive)), "wrong", 0)
+
+	//assert
+	assert.Equal(t, ErrPassword, err)
+}
+
 func TestMain(m *testing.M) {
 	//if anything fails before and files are still present
 	utils.RemoveAllExcept(filepath.Join(testData.OutPath, ".gitkeep"))
@@ -84,3 +178,9 @@ func TestMain(m *testing.M) {
 	//cleaning
 	utils.RemoveAllExcept(filepath.Join(testData.OutPath, ".gitkeep"))
 }
+
+func assertContent(t *testing.T, file string, expected string) {
+	content, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}